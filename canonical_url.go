@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters added by newsletters, social networks
+// and analytics that don't change what a URL points to.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"ref_src": true,
+}
+
+// canonicalURL returns the form of a URL used to tell whether two posts or
+// feeds are the same, so that variants of one address don't get stored
+// twice. The scheme and host are lowercased, http is treated as https,
+// default ports, the fragment, tracking parameters and any trailing slash
+// are removed, and the remaining parameters are sorted. The original URL is
+// still the one stored for display and fetching; URLs that don't parse are
+// returned unchanged.
+func canonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme == "http" {
+		parsed.Scheme = "https"
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if port == "" || port == "80" || port == "443" {
+		parsed.Host = host
+	} else {
+		parsed.Host = host + ":" + port
+	}
+
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	parsed.RawPath = strings.TrimSuffix(parsed.RawPath, "/")
+
+	query := parsed.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	// Encode sorts the parameters by key
+	parsed.RawQuery = query.Encode()
+	parsed.ForceQuery = false
+
+	return parsed.String()
+}
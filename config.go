@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func requireEnv(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		log.Fatalf("%s environment variable not set", key)
+	}
+	return value
+}
+
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Fatalf("%s must be a non-negative integer, got %q", key, value)
+	}
+	return parsed
+}
+
+func envInt64(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("%s must be a positive integer, got %q", key, value)
+	}
+	return parsed
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("%s must be a positive duration, got %q", key, value)
+	}
+	return parsed
+}
+
+func envBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s must be true or false, got %q", key, value)
+	}
+	return parsed
+}
+
+// loadFetcherConfig reads the outbound HTTP settings used by the scraper.
+// Proxies are configured through the standard HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY variables.
+func loadFetcherConfig() fetcherConfig {
+	return fetcherConfig{
+		UserAgent:    envString("FEED_USER_AGENT", defaultUserAgent),
+		Timeout:      envDuration("FEED_TIMEOUT", 10*time.Second),
+		MaxRedirects: envInt("FEED_MAX_REDIRECTS", 5),
+		MaxBodyBytes: envInt64("FEED_MAX_BODY_BYTES", 10<<20),
+	}
+}
+
+// loadRetentionConfig reads the post retention policy. Both limits are off
+// unless set.
+func loadRetentionConfig() retentionConfig {
+	return retentionConfig{
+		MaxAge:          envDuration("RETENTION_MAX_AGE", 0),
+		MaxPostsPerFeed: envInt("RETENTION_MAX_POSTS_PER_FEED", 0),
+		Interval:        envDuration("RETENTION_INTERVAL", time.Hour),
+		BatchSize:       int(envInt64("RETENTION_BATCH_SIZE", 1000)),
+	}
+}
+
+// loadWebhookConfig reads how webhook deliveries are sent and retried.
+func loadWebhookConfig() webhookConfig {
+	return webhookConfig{
+		Timeout:     envDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts: int(envInt64("WEBHOOK_MAX_ATTEMPTS", 8)),
+		RetryDelay:  envDuration("WEBHOOK_RETRY_DELAY", 30*time.Second),
+		Interval:    10 * time.Second,
+		BatchSize:   20,
+
+		AllowPrivateNetworks: envBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+	}
+}
+
+// dbPoolConfig sizes the database/sql connection pool.
+type dbPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectTimeout  time.Duration
+}
+
+func loadDBPoolConfig() dbPoolConfig {
+	return dbPoolConfig{
+		MaxOpenConns:    envInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    envInt("DB_MAX_IDLE_CONNS", 5),
+		ConnMaxLifetime: envDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: envDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		ConnectTimeout:  envDuration("DB_CONNECT_TIMEOUT", 10*time.Second),
+	}
+}
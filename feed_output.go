@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+// atomFeed is the Atom 1.0 document served at /v1/users/{userID}/feed.atom.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// rssFeed is the RSS 2.0 document served at /v1/users/{userID}/feed.rss.
+// Authors go in dc:creator since RSS's own author element wants an email.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	SelfLink      atomLink   `xml:"atom:link"`
+	Items         []rssEntry `xml:"item"`
+}
+
+type rssEntry struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	Description string         `xml:"description,omitempty"`
+	GUID        rssGUID        `xml:"guid"`
+	PubDate     string         `xml:"pubDate,omitempty"`
+	Creators    []string       `xml:"dc:creator"`
+	Categories  []string       `xml:"category"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// jsonFeed is the JSON Feed 1.1 document served at
+// /v1/users/{userID}/feed.json, for clients without an XML parser.
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	ContentText   string               `json:"content_text,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	SizeInBytes       *int64 `json:"size_in_bytes,omitempty"`
+	DurationInSeconds *int64 `json:"duration_in_seconds,omitempty"`
+}
+
+// newJSONFeed renders the posts of a user's timeline as a JSON Feed. Items
+// must have content_html or content_text, so the description stands in for
+// posts without full content.
+func newJSONFeed(user database.User, posts []Post, selfURL string) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Blog-aggr: " + user.Name,
+		FeedURL:     selfURL,
+		Description: "Posts of the feeds followed by " + user.Name,
+		Authors:     []jsonFeedAuthor{{Name: user.Name}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, post := range posts {
+		item := jsonFeedItem{
+			ID:           post.ID.String(),
+			URL:          post.Url,
+			Title:        post.Title,
+			DateModified: post.UpdatedAt.UTC().Format(time.RFC3339),
+			Tags:         post.Categories,
+		}
+		if post.ContentHTML != nil {
+			item.ContentHTML = *post.ContentHTML
+		} else if post.Description.Valid {
+			item.ContentHTML = post.Description.String
+		}
+		if post.ContentText != nil {
+			item.ContentText = *post.ContentText
+		}
+		if item.ContentHTML == "" && item.ContentText == "" {
+			item.ContentText = post.Title
+		}
+		if post.ContentHTML != nil && post.Description.Valid {
+			item.Summary = htmlToText(post.Description.String)
+		}
+		if post.PublishedAt.Valid {
+			item.DatePublished = post.PublishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		for _, author := range post.Authors {
+			item.Authors = append(item.Authors, jsonFeedAuthor{Name: author})
+		}
+		for _, enclosure := range post.Enclosures {
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{
+				URL:               enclosure.URL,
+				MimeType:          enclosure.MimeType,
+				SizeInBytes:       enclosure.Length,
+				DurationInSeconds: enclosure.DurationSeconds,
+			})
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
+
+// newAtomFeed renders the posts of a user's timeline as an Atom feed. selfURL
+// is the address of the feed itself, without its token.
+func newAtomFeed(user database.User, posts []Post, selfURL string) atomFeed {
+	feed := atomFeed{
+		ID:      "urn:uuid:" + user.ID.String(),
+		Title:   "Blog-aggr: " + user.Name,
+		Updated: lastUpdated(posts).Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "self", Href: selfURL, Type: "application/atom+xml"}},
+		Author:  atomPerson{Name: user.Name},
+	}
+	for _, post := range posts {
+		entry := atomEntry{
+			ID:      "urn:uuid:" + post.ID.String(),
+			Title:   post.Title,
+			Updated: post.UpdatedAt.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Rel: "alternate", Href: post.Url, Type: "text/html"}},
+		}
+		if post.PublishedAt.Valid {
+			entry.Published = post.PublishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		for _, author := range post.Authors {
+			entry.Authors = append(entry.Authors, atomPerson{Name: author})
+		}
+		for _, category := range post.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		for _, enclosure := range post.Enclosures {
+			link := atomLink{Rel: "enclosure", Href: enclosure.URL, Type: enclosure.MimeType}
+			if enclosure.Length != nil {
+				link.Length = strconv.FormatInt(*enclosure.Length, 10)
+			}
+			entry.Links = append(entry.Links, link)
+		}
+		if post.Description.Valid && post.Description.String != "" {
+			entry.Summary = &atomText{Type: "html", Body: post.Description.String}
+		}
+		if post.ContentHTML != nil {
+			entry.Content = &atomText{Type: "html", Body: *post.ContentHTML}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// newRSSFeed renders the posts of a user's timeline as an RSS 2.0 feed.
+func newRSSFeed(user database.User, posts []Post, selfURL string) rssFeed {
+	feed := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         "Blog-aggr: " + user.Name,
+			Link:          selfURL,
+			Description:   "Posts of the feeds followed by " + user.Name,
+			LastBuildDate: lastUpdated(posts).Format(time.RFC1123Z),
+			SelfLink:      atomLink{Rel: "self", Href: selfURL, Type: "application/rss+xml"},
+		},
+	}
+	for _, post := range posts {
+		item := rssEntry{
+			Title:      post.Title,
+			Link:       post.Url,
+			GUID:       rssGUID{Value: "urn:uuid:" + post.ID.String()},
+			Creators:   post.Authors,
+			Categories: post.Categories,
+		}
+		// Readers show the description; prefer the full content if any
+		if post.ContentHTML != nil {
+			item.Description = *post.ContentHTML
+		} else if post.Description.Valid {
+			item.Description = post.Description.String
+		}
+		if post.PublishedAt.Valid {
+			item.PubDate = post.PublishedAt.Time.Format(time.RFC1123Z)
+		}
+		for _, enclosure := range post.Enclosures {
+			// length is required by RSS; 0 stands for unknown
+			length := "0"
+			if enclosure.Length != nil {
+				length = strconv.FormatInt(*enclosure.Length, 10)
+			}
+			item.Enclosures = append(item.Enclosures, rssEnclosure{
+				URL:    enclosure.URL,
+				Length: length,
+				Type:   enclosure.MimeType,
+			})
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+// lastUpdated returns when the most recently updated post changed, or now if
+// there are no posts.
+func lastUpdated(posts []Post) time.Time {
+	var last time.Time
+	for _, post := range posts {
+		if post.UpdatedAt.After(last) {
+			last = post.UpdatedAt
+		}
+	}
+	if last.IsZero() {
+		return time.Now().UTC()
+	}
+	return last.UTC()
+}
+
+func respondWithXML(w http.ResponseWriter, status int, contentType string, payload interface{}) {
+	dat, err := xml.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling XML: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-type", contentType)
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(dat)
+}
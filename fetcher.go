@@ -0,0 +1,280 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const defaultUserAgent = "Blog-aggr/1.0 (+https://github.com/L-PDufour/Blog-aggr)"
+
+type fetcherConfig struct {
+	UserAgent    string
+	Timeout      time.Duration
+	MaxRedirects int
+	MaxBodyBytes int64
+}
+
+// feedFetcher is shared by all scraping goroutines so that connections to the
+// same hosts are reused between fetches.
+type feedFetcher struct {
+	client       *http.Client
+	userAgent    string
+	maxBodyBytes int64
+}
+
+func newFeedFetcher(cfg fetcherConfig) *feedFetcher {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		// Content-Encoding is negotiated and decoded in fetchRSS so that
+		// brotli is supported and the size limit applies to decoded bytes.
+		DisableCompression: true,
+	}
+
+	return &feedFetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > cfg.MaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+				}
+				// Custom headers may carry credentials meant for the feed's
+				// own host only
+				if req.URL.Host != via[0].URL.Host {
+					headers, _ := req.Context().Value(requestHeadersKey{}).(map[string]string)
+					for name := range headers {
+						req.Header.Del(name)
+					}
+					setDefaultRequestHeaders(req, cfg.UserAgent)
+				}
+				return nil
+			},
+		},
+		userAgent:    cfg.UserAgent,
+		maxBodyBytes: cfg.MaxBodyBytes,
+	}
+}
+
+// feedContentTypes lists the media types we accept as a feed document. Any
+// other "+xml" type is accepted as well, as are responses without a type.
+var feedContentTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/xml":       true,
+	"application/x-rss+xml": true,
+	"text/xml":              true,
+	"text/plain":            true,
+}
+
+func isFeedContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return feedContentTypes[mediaType] || strings.HasSuffix(mediaType, "+xml")
+}
+
+// limitedReader is like io.LimitedReader but fails with a BodyTooLargeError
+// instead of silently truncating the body.
+type limitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, &BodyTooLargeError{Limit: l.limit}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// decodeContentEncoding returns the decoded body of resp. Closing it doesn't
+// close resp.Body.
+func decodeContentEncoding(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return flate.NewReader(resp.Body), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(resp.Body)), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// parseRequestHeaders decodes the per-feed headers stored in
+// feeds.request_headers.
+func parseRequestHeaders(raw json.RawMessage) (map[string]string, error) {
+	headers := map[string]string{}
+	if len(raw) == 0 {
+		return headers, nil
+	}
+	err := json.Unmarshal(raw, &headers)
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// reservedRequestHeaders can't be overridden per feed because the fetcher
+// manages them itself.
+var reservedRequestHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+}
+
+func validateRequestHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if reservedRequestHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %q can't be overridden", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %q", name)
+		}
+	}
+	return nil
+}
+
+// requestHeadersKey is the context key under which fetchRSS passes the custom
+// headers of a feed to the redirect policy.
+type requestHeadersKey struct{}
+
+// setDefaultRequestHeaders sets the headers the fetcher sends with every
+// request.
+func setDefaultRequestHeaders(req *http.Request, userAgent string) {
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.1")
+	req.Header.Set("Accept-Encoding", "br, gzip, deflate")
+}
+
+// fetchResult is a successfully fetched feed document.
+type fetchResult struct {
+	RSS *RSS
+	// URL is where the document was fetched from after any redirects, which
+	// relative URLs in it are resolved against.
+	URL string
+	// PermanentURL is set when the feed moved: the request went through one or
+	// more permanent redirects (301/308) and this is where they lead.
+	PermanentURL string
+}
+
+// permanentRedirectURL walks back the redirect chain that produced resp and
+// returns the URL reached by following permanent redirects only, or an empty
+// string if the first hop wasn't permanent.
+func permanentRedirectURL(resp *http.Response) string {
+	var hops []*http.Request
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		hops = append([]*http.Request{req}, hops...)
+	}
+
+	permanentURL := ""
+	for _, hop := range hops {
+		status := hop.Response.StatusCode
+		if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+			break
+		}
+		permanentURL = hop.URL.String()
+	}
+	return permanentURL
+}
+
+func (f *feedFetcher) fetchRSS(ctx context.Context, url string, headers map[string]string) (*fetchResult, error) {
+	ctx = context.WithValue(ctx, requestHeadersKey{}, headers)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	setDefaultRequestHeaders(req, f.userAgent)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isFeedContentType(contentType) {
+		return nil, &ContentTypeError{ContentType: contentType}
+	}
+
+	if resp.ContentLength > f.maxBodyBytes {
+		return nil, &BodyTooLargeError{Limit: f.maxBodyBytes}
+	}
+
+	decoded, err := decodeContentEncoding(resp)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	defer decoded.Close()
+
+	body := &limitedReader{r: decoded, limit: f.maxBodyBytes, remaining: f.maxBodyBytes}
+	var rss RSS
+	err = xml.NewDecoder(body).Decode(&rss)
+	if err != nil {
+		var tooLargeErr *BodyTooLargeError
+		if errors.As(err, &tooLargeErr) {
+			return nil, tooLargeErr
+		}
+		return nil, &ParseError{Err: err}
+	}
+
+	return &fetchResult{
+		RSS:          &rss,
+		URL:          resp.Request.URL.String(),
+		PermanentURL: permanentRedirectURL(resp),
+	}, nil
+}
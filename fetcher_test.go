@@ -0,0 +1,77 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestFetcher() *feedFetcher {
+	return newFeedFetcher(fetcherConfig{
+		UserAgent:    defaultUserAgent,
+		Timeout:      5 * time.Second,
+		MaxRedirects: 5,
+		MaxBodyBytes: 1 << 20,
+	})
+}
+
+func TestFetchRSSGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testRSS))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	result, err := newTestFetcher().fetchRSS(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(result.RSS.Channel.Items); got != 2 {
+		t.Errorf("got %d items, want 2", got)
+	}
+}
+
+func TestFetchRSSRedirectHeaders(t *testing.T) {
+	var got http.Header
+	feed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(testRSS))
+	})
+	other := httptest.NewServer(feed)
+	defer other.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/feed", feed)
+	mux.Handle("/same-host", http.RedirectHandler("/feed", http.StatusFound))
+	mux.Handle("/other-host", http.RedirectHandler(other.URL+"/feed", http.StatusFound))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	headers := map[string]string{"X-Api-Token": "secret", "User-Agent": "Custom/1.0"}
+	tests := []struct {
+		path      string
+		token     string
+		userAgent string
+	}{
+		{"/same-host", "secret", "Custom/1.0"},
+		{"/other-host", "", defaultUserAgent},
+	}
+	for _, tt := range tests {
+		_, err := newTestFetcher().fetchRSS(context.Background(), srv.URL+tt.path, headers)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if token := got.Get("X-Api-Token"); token != tt.token {
+			t.Errorf("%s: X-Api-Token %q, want %q", tt.path, token, tt.token)
+		}
+		if userAgent := got.Get("User-Agent"); userAgent != tt.userAgent {
+			t.Errorf("%s: User-Agent %q, want %q", tt.path, userAgent, tt.userAgent)
+		}
+	}
+}
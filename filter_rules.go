@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// Fields of a post a filter rule can match, stored in filter_rules.field.
+const (
+	ruleFieldTitle    = "title"
+	ruleFieldContent  = "content"
+	ruleFieldAuthor   = "author"
+	ruleFieldCategory = "category"
+)
+
+// How the pattern of a filter rule is matched, stored in
+// filter_rules.pattern_type. Literal patterns match anywhere in the field,
+// ignoring case; regular expressions use Go's syntax, so (?i) makes them
+// ignore case too.
+const (
+	patternLiteral = "literal"
+	patternRegex   = "regex"
+)
+
+// What a filter rule does to the posts it matches, stored in
+// filter_rules.action.
+const (
+	ruleActionHide      = "hide"
+	ruleActionHighlight = "highlight"
+	ruleActionMarkRead  = "mark_read"
+)
+
+// ruleSubject is what filter rules are matched against, taken from either a
+// post being stored or a stored one.
+type ruleSubject struct {
+	FeedID     uuid.UUID
+	Title      string
+	Content    string
+	Authors    []string
+	Categories []string
+}
+
+// filterRule is a filter rule ready to be matched.
+type filterRule struct {
+	database.FilterRule
+	re *regexp.Regexp
+}
+
+// compileFilterRules prepares rules for matching. Regular expressions are
+// checked when a rule is saved, so one that doesn't compile is only logged
+// and skipped.
+func compileFilterRules(rules []database.FilterRule) []filterRule {
+	var compiled []filterRule
+	for _, rule := range rules {
+		current := filterRule{FilterRule: rule}
+		if rule.PatternType == patternRegex {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				log.Printf("Couldn't compile filter rule %s: %v", rule.ID, err)
+				continue
+			}
+			current.re = re
+		}
+		compiled = append(compiled, current)
+	}
+	return compiled
+}
+
+func (rule filterRule) matches(subject ruleSubject) bool {
+	if rule.FeedID.Valid && rule.FeedID.UUID != subject.FeedID {
+		return false
+	}
+	switch rule.Field {
+	case ruleFieldTitle:
+		return rule.matchString(subject.Title)
+	case ruleFieldContent:
+		return rule.matchString(subject.Content)
+	case ruleFieldAuthor:
+		return rule.matchAny(subject.Authors)
+	case ruleFieldCategory:
+		return rule.matchAny(subject.Categories)
+	}
+	return false
+}
+
+func (rule filterRule) matchString(value string) bool {
+	if rule.re != nil {
+		return rule.re.MatchString(value)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(rule.Pattern))
+}
+
+func (rule filterRule) matchAny(values []string) bool {
+	for _, value := range values {
+		if rule.matchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRuleSubject returns what rules match in a post being stored. Content
+// covers both the description and the full text of the post.
+func newRuleSubject(post database.CreatePostParams) ruleSubject {
+	return ruleSubject{
+		FeedID:     post.FeedID,
+		Title:      post.Title,
+		Content:    joinContent(post.Description, post.ContentText.String),
+		Authors:    decodeStringList(post.Authors),
+		Categories: decodeStringList(post.Categories),
+	}
+}
+
+func postRuleSubject(post Post) ruleSubject {
+	contentText := ""
+	if post.ContentText != nil {
+		contentText = *post.ContentText
+	}
+	return ruleSubject{
+		FeedID:     post.FeedID,
+		Title:      post.Title,
+		Content:    joinContent(post.Description, contentText),
+		Authors:    post.Authors,
+		Categories: post.Categories,
+	}
+}
+
+// joinContent returns the text of a post's description followed by its full
+// text, if any.
+func joinContent(description sql.NullString, contentText string) string {
+	text := ""
+	if description.Valid {
+		text = htmlToText(description.String)
+	}
+	if contentText != "" {
+		text += "\n" + contentText
+	}
+	return text
+}
+
+// applyHideRules records which of the posts a fetch inserted the hide rules
+// of the feed's followers match, so that they are left out of their
+// timelines before these are paginated. The matches are stored in a single
+// batch.
+func applyHideRules(ctx context.Context, db database.Store, feedID uuid.UUID, posts []database.CreatePostParams) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	rules, err := db.GetHideRulesForFeed(ctx, feedID)
+	if err != nil {
+		return err
+	}
+	compiled := compileFilterRules(rules)
+	if len(compiled) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var matches []database.CreatePostRuleMatchParams
+	for _, post := range posts {
+		subject := newRuleSubject(post)
+		for _, rule := range compiled {
+			if rule.matches(subject) {
+				matches = append(matches, database.CreatePostRuleMatchParams{
+					CreatedAt: now,
+					RuleID:    rule.ID,
+					PostID:    post.ID,
+				})
+			}
+		}
+	}
+	return db.CreatePostRuleMatches(ctx, matches)
+}
+
+// applyFilterRules matches the user's rules against a page of posts: it
+// drops those a hide rule matches, which covers the posts stored before the
+// rule, and flags those matched by highlight and mark_read rules.
+func applyFilterRules(posts []Post, rules []filterRule) []Post {
+	if len(rules) == 0 {
+		return posts
+	}
+	result := posts[:0]
+	for _, post := range posts {
+		subject := postRuleSubject(post)
+		hidden := false
+		for _, rule := range rules {
+			if !rule.matches(subject) {
+				continue
+			}
+			switch rule.Action {
+			case ruleActionHide:
+				hidden = true
+			case ruleActionHighlight:
+				post.Highlighted = true
+			case ruleActionMarkRead:
+				post.Read = true
+			}
+		}
+		if !hidden {
+			result = append(result, post)
+		}
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+const (
+	// minTitleWords is how many words a title needs to identify a story on
+	// its own; shorter ones ("Links", "Episode 12") are too common.
+	minTitleWords = 4
+	// minSimhashWords is how many words of content are needed for a
+	// meaningful simhash.
+	minSimhashWords = 50
+	// maxSimhashDistance is how many bits two simhashes may differ by for
+	// their posts to be considered the same story. Candidates are looked up
+	// by the four 16-bit bands of the simhash, which finds them all only as
+	// long as this stays below 4.
+	maxSimhashDistance = 3
+)
+
+// titleKey normalizes a post title for comparison: lowercased, punctuation
+// removed and whitespace collapsed. Titles too short to be telling give an
+// empty key.
+func titleKey(title string) string {
+	words := words(title)
+	if len(words) < minTitleWords {
+		return ""
+	}
+	return strings.Join(words, " ")
+}
+
+// contentSimhash returns a 64-bit simhash of the plain-text content of a post,
+// computed over its three-word shingles, so that near-identical texts get
+// hashes a few bits apart. Content too short to compare gives 0.
+func contentSimhash(text string) int64 {
+	words := words(text)
+	if len(words) < minSimhashWords {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i+3 <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+3], " ")))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return int64(hash)
+}
+
+// words splits text into lowercased words, dropping punctuation.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// sameStory reports whether a new post tells the same story as a stored one:
+// they share a canonical URL, a title or nearly the same content.
+func sameStory(post database.CreatePostParams, other database.GetPostFingerprintCandidatesRow) bool {
+	if post.CanonicalUrl == other.CanonicalUrl {
+		return true
+	}
+	if post.TitleKey != "" && post.TitleKey == other.TitleKey {
+		return true
+	}
+	if post.Simhash == 0 || other.Simhash == 0 {
+		return false
+	}
+	return bits.OnesCount64(uint64(post.Simhash^other.Simhash)) <= maxSimhashDistance
+}
+
+// assignClusters puts each post in the cluster of a post of another feed
+// stored within window that tells the same story, or in a cluster of its
+// own. Posts of the same feed are never grouped: a feed repeating a title is
+// publishing something new.
+func assignClusters(ctx context.Context, db database.Store, posts []database.CreatePostParams, window time.Duration) error {
+	for i := range posts {
+		posts[i].ClusterID = posts[i].ID
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+
+	known, err := db.GetPostFingerprintCandidates(ctx, time.Now().UTC().Add(-window), posts)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		for _, other := range known {
+			if other.FeedID != posts[i].FeedID && sameStory(posts[i], other) {
+				posts[i].ClusterID = other.ClusterID
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+func TestAssignClusters(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor ", 8)
+	withText := func(post database.CreatePostParams, text string) database.CreatePostParams {
+		post.Simhash = contentSimhash(text)
+		return post
+	}
+
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		ctx := context.Background()
+		userID := newTestAPI(t, db).user.ID
+		feed, _ := createTestFeed(t, db, userID, "https://a.example/feed")
+		other, _ := createTestFeed(t, db, userID, "https://b.example/feed")
+
+		stored := []database.CreatePostParams{
+			newTestPost(feed.ID, "https://a.example/1?utm_source=rss", "Short"),
+			newTestPost(feed.ID, "https://a.example/2", "A long enough title here"),
+			withText(newTestPost(feed.ID, "https://a.example/3", "Lorem"), text),
+			newTestPost(feed.ID, "https://a.example/4", "Same"),
+		}
+		if err := assignClusters(ctx, db, stored, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		storeTestPosts(t, db, stored...)
+
+		tests := []struct {
+			name string
+			post database.CreatePostParams
+			// want is the index of the stored post whose cluster it joins,
+			// -1 for a cluster of its own
+			want int
+		}{
+			{"canonical URL", newTestPost(other.ID, "https://a.example/1", "Other"), 0},
+			{"title", newTestPost(other.ID, "https://b.example/2", "a LONG enough title, here!"), 1},
+			{"content", withText(newTestPost(other.ID, "https://b.example/3", "Ipsum"), text+" extra"), 2},
+			{"short title", newTestPost(other.ID, "https://b.example/4", "Same"), -1},
+			{"same feed", newTestPost(feed.ID, "https://a.example/5", "A long enough title here"), -1},
+		}
+		posts := make([]database.CreatePostParams, len(tests))
+		for i, tt := range tests {
+			posts[i] = tt.post
+		}
+		if err := assignClusters(ctx, db, posts, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		for i, tt := range tests {
+			want := posts[i].ID
+			if tt.want >= 0 {
+				want = stored[tt.want].ClusterID
+			}
+			if posts[i].ClusterID != want {
+				t.Errorf("%s: post joined cluster %s, want %s", tt.name, posts[i].ClusterID, want)
+			}
+		}
+
+		// Posts stored before the window are left out
+		late := []database.CreatePostParams{newTestPost(other.ID, "https://a.example/1", "Other")}
+		if err := assignClusters(ctx, db, late, -time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if late[0].ClusterID == stored[0].ClusterID {
+			t.Error("post joined the cluster of a post stored before the window")
+		}
+	})
+}
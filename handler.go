@@ -1,12 +1,40 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
-	type bodyResponse struct {
-		Status string `json:"status"`
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// handlerLiveness reports that the process is up and serving requests. It
+// doesn't touch the database, so that an outage there doesn't get the
+// process restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, 200, healthResponse{
+		Status: "ok",
+	})
+}
+
+// handlerReadiness reports whether the API can serve traffic, i.e. whether
+// the database is reachable.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	err := cfg.DB.Ping(ctx)
+	if err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		respondWithJSON(w, http.StatusServiceUnavailable, healthResponse{
+			Status: "database unavailable",
+		})
+		return
 	}
-	respondWithJSON(w, 200, bodyResponse{
+	respondWithJSON(w, 200, healthResponse{
 		Status: "ok",
 	})
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerPostCategories(w http.ResponseWriter, r *http.Request, user database.User) {
+	name, ok := decodeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := cfg.DB.CreateCategory(r.Context(), database.CreateCategoryParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		Name:      name,
+	})
+	if database.IsUniqueViolation(err) {
+		respondWithERROR(w, http.StatusConflict, "Category already exists")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, databaseCategoryToCategory(category))
+}
+
+func (cfg *apiConfig) handlerGetCategories(w http.ResponseWriter, r *http.Request, user database.User) {
+	categories, err := cfg.DB.GetCategoriesForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get categories")
+		return
+	}
+
+	result := make([]Category, len(categories))
+	for i, category := range categories {
+		result[i] = databaseCategoryToCategory(category)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerPutCategory renames a category of the user.
+func (cfg *apiConfig) handlerPutCategory(w http.ResponseWriter, r *http.Request, user database.User) {
+	categoryID, err := uuid.Parse(r.PathValue("categoryID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	name, ok := decodeCategoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := cfg.DB.RenameCategory(r.Context(), database.RenameCategoryParams{
+		ID:     categoryID,
+		UserID: user.ID,
+		Name:   name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find category")
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		respondWithERROR(w, http.StatusConflict, "Category already exists")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't rename category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseCategoryToCategory(category))
+}
+
+// handlerDeleteCategory deletes a category of the user. The follows filed
+// into it are kept, uncategorized.
+func (cfg *apiConfig) handlerDeleteCategory(w http.ResponseWriter, r *http.Request, user database.User) {
+	categoryID, err := uuid.Parse(r.PathValue("categoryID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteCategory(r.Context(), database.DeleteCategoryParams{
+		ID:     categoryID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't delete category")
+		return
+	}
+	if deleted == 0 {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find category")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeCategoryName reads the name of a category from the request body. It
+// responds with an error itself and returns false if the name is missing.
+func decodeCategoryName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return "", false
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respondWithERROR(w, http.StatusBadRequest, "Category name is required")
+		return "", false
+	}
+	return name, true
+}
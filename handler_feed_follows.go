@@ -1,8 +1,11 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/L-PDufour/Blog-aggr/internal/database"
@@ -56,6 +59,106 @@ func (cfg *apiConfig) handlerPostFeedFollows(w http.ResponseWriter, r *http.Requ
 	respondWithJSON(w, http.StatusOK, databaseFeedFollowToFeedFollow(feedFollow))
 }
 
+// handlerPatchFeedFollow changes the user's settings of a follow. Settings left
+// out of the body are kept; an empty custom_title goes back to the feed's
+// name. Muted feeds are left out of the timeline, and notify is either all or
+// none.
+func (cfg *apiConfig) handlerPatchFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedFollowID, err := uuid.Parse(r.PathValue("feedFollowID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	type parameters struct {
+		CustomTitle *string `json:"custom_title"`
+		Muted       *bool   `json:"muted"`
+		Notify      *string `json:"notify"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.Notify != nil && *params.Notify != notifyAll && *params.Notify != notifyNone {
+		respondWithERROR(w, http.StatusBadRequest, "Notify must be either all or none")
+		return
+	}
+
+	arg := database.UpdateFeedFollowParams{
+		ID:     feedFollowID,
+		UserID: user.ID,
+	}
+	if params.CustomTitle != nil {
+		arg.CustomTitle = sql.NullString{String: strings.TrimSpace(*params.CustomTitle), Valid: true}
+	}
+	if params.Muted != nil {
+		arg.Muted = sql.NullBool{Bool: *params.Muted, Valid: true}
+	}
+	if params.Notify != nil {
+		arg.Notify = sql.NullString{String: *params.Notify, Valid: true}
+	}
+
+	feedFollow, err := cfg.DB.UpdateFeedFollow(r.Context(), arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed follow")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't update feed follow")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseFeedFollowToFeedFollow(feedFollow))
+}
+
+// handlerPutFeedFollowCategory files a follow into one of the user's
+// categories, or takes it out of its category if category_id is null.
+func (cfg *apiConfig) handlerPutFeedFollowCategory(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedFollowID, err := uuid.Parse(r.PathValue("feedFollowID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	type parameters struct {
+		CategoryID *uuid.UUID `json:"category_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	var categoryID uuid.NullUUID
+	if params.CategoryID != nil {
+		categoryID = uuid.NullUUID{UUID: *params.CategoryID, Valid: true}
+	}
+
+	feedFollow, err := cfg.DB.SetFeedFollowCategory(r.Context(), database.SetFeedFollowCategoryParams{
+		ID:         feedFollowID,
+		UserID:     user.ID,
+		CategoryID: categoryID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed follow or category")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't update feed follow")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseFeedFollowToFeedFollow(feedFollow))
+}
+
 func (cfg *apiConfig) handlerFeedFollowsGet(w http.ResponseWriter, r *http.Request, user database.User) {
 	feedFollows, err := cfg.DB.GetFeedFollowsForUser(r.Context(), user.ID)
 	if err != nil {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,8 +14,9 @@ import (
 
 func (cfg *apiConfig) handlerPostFeeds(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		Name    string            `json:"name"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -25,27 +29,62 @@ func (cfg *apiConfig) handlerPostFeeds(w http.ResponseWriter, r *http.Request, u
 		return
 	}
 
-	feed, err := cfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		UserID:    user.ID,
-		Name:      params.Name,
-		Url:       params.URL,
-	})
+	requestHeaders, err := encodeRequestHeaders(params.Headers)
 	if err != nil {
-		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create feed")
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	feedFollow, err := cfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		UserID:    user.ID,
-		FeedID:    feed.ID,
+
+	// The URL may belong to an existing feed, possibly as the old address of
+	// a feed that has since moved or as a variant of its address
+	existing, err := cfg.DB.GetFeedByURL(r.Context(), database.GetFeedByURLParams{
+		Url:          params.URL,
+		CanonicalUrl: canonicalURL(params.URL),
+	})
+	if err == nil {
+		respondWithERROR(w, http.StatusConflict, "Feed already exists with id "+existing.ID.String())
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't look up feed")
+		return
+	}
+
+	// Create the feed and follow it together, so that a failed follow doesn't
+	// leave the user owning a feed they don't follow
+	var feed database.Feed
+	var feedFollow database.FeedFollow
+	err = cfg.DB.InTx(r.Context(), func(tx database.Store) error {
+		feed, err = tx.CreateFeed(r.Context(), database.CreateFeedParams{
+			ID:             uuid.New(),
+			CreatedAt:      time.Now().UTC(),
+			UpdatedAt:      time.Now().UTC(),
+			UserID:         user.ID,
+			Name:           params.Name,
+			Url:            params.URL,
+			RequestHeaders: requestHeaders,
+			CanonicalUrl:   canonicalURL(params.URL),
+		})
+		if err != nil {
+			return err
+		}
+		feedFollow, err = tx.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		return err
 	})
+	if database.IsUniqueViolation(err) {
+		// Another request added the same URL since we looked it up
+		respondWithERROR(w, http.StatusConflict, "Feed already exists")
+		return
+	}
 	if err != nil {
-		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create feed follow")
+		log.Printf("Couldn't create feed %s: %v", params.URL, err)
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create feed")
 		return
 	}
 
@@ -65,5 +104,104 @@ func (cfg *apiConfig) handlerGetFeeds(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to fetch feeds", http.StatusInternalServerError)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, feeds)
+	respondWithJSON(w, http.StatusOK, databaseFeedsToFeeds(feeds))
+}
+
+// handlerPutFeedHeaders replaces the custom request headers sent when fetching
+// a feed, e.g. to rotate an auth token. Only the feed's owner may change them.
+func (cfg *apiConfig) handlerPutFeedHeaders(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	type parameters struct {
+		Headers map[string]string `json:"headers"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	requestHeaders, err := encodeRequestHeaders(params.Headers)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, err := cfg.DB.UpdateFeedRequestHeaders(r.Context(), database.UpdateFeedRequestHeadersParams{
+		ID:             feedID,
+		UserID:         user.ID,
+		RequestHeaders: requestHeaders,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't update feed headers")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseFeedToFeed(feed))
+}
+
+// handlerPutFeedStatus lets the feed's owner pause or resume polling. Resuming
+// also clears a gone or broken state, e.g. after the feed was fixed upstream.
+func (cfg *apiConfig) handlerPutFeedStatus(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	type parameters struct {
+		Status string `json:"status"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.Status != feedStatusActive && params.Status != feedStatusPaused {
+		respondWithERROR(w, http.StatusBadRequest, "Status must be either active or paused")
+		return
+	}
+
+	feed, err := cfg.DB.SetFeedStatus(r.Context(), database.SetFeedStatusParams{
+		ID:     feedID,
+		UserID: user.ID,
+		Status: params.Status,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't update feed status")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseFeedToFeed(feed))
+}
+
+func encodeRequestHeaders(headers map[string]string) (json.RawMessage, error) {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	err := validateRequestHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(headers)
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerPostFilterRules adds a filter rule for the user. It applies to the
+// posts of every feed the user follows, or only to those of feed_id.
+func (cfg *apiConfig) handlerPostFilterRules(w http.ResponseWriter, r *http.Request, user database.User) {
+	arg, ok := decodeFilterRule(w, r)
+	if !ok {
+		return
+	}
+	arg.ID = uuid.New()
+	arg.CreatedAt = time.Now().UTC()
+	arg.UpdatedAt = time.Now().UTC()
+	arg.UserID = user.ID
+
+	rule, err := cfg.DB.CreateFilterRule(r.Context(), arg)
+	if database.IsForeignKeyViolation(err) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create filter rule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, databaseFilterRuleToFilterRule(rule))
+}
+
+func (cfg *apiConfig) handlerGetFilterRules(w http.ResponseWriter, r *http.Request, user database.User) {
+	rules, err := cfg.DB.GetFilterRulesForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get filter rules")
+		return
+	}
+
+	result := make([]FilterRule, len(rules))
+	for i, rule := range rules {
+		result[i] = databaseFilterRuleToFilterRule(rule)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerPutFilterRule replaces a filter rule of the user. The posts the
+// previous version hid when they were stored show up again unless the new
+// one matches them too.
+func (cfg *apiConfig) handlerPutFilterRule(w http.ResponseWriter, r *http.Request, user database.User) {
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	arg, ok := decodeFilterRule(w, r)
+	if !ok {
+		return
+	}
+
+	var rule database.FilterRule
+	err = cfg.DB.InTx(r.Context(), func(tx database.Store) error {
+		var err error
+		rule, err = tx.UpdateFilterRule(r.Context(), database.UpdateFilterRuleParams{
+			FeedID:      arg.FeedID,
+			Field:       arg.Field,
+			PatternType: arg.PatternType,
+			Pattern:     arg.Pattern,
+			Action:      arg.Action,
+			ID:          ruleID,
+			UserID:      user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.DeletePostRuleMatches(r.Context(), rule.ID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find filter rule")
+		return
+	}
+	if database.IsForeignKeyViolation(err) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't update filter rule")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseFilterRuleToFilterRule(rule))
+}
+
+func (cfg *apiConfig) handlerDeleteFilterRule(w http.ResponseWriter, r *http.Request, user database.User) {
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteFilterRule(r.Context(), database.DeleteFilterRuleParams{
+		ID:     ruleID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't delete filter rule")
+		return
+	}
+	if deleted == 0 {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find filter rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userFilterRules returns the user's filter rules, ready to be matched
+// against their posts.
+func (cfg *apiConfig) userFilterRules(ctx context.Context, userID uuid.UUID) ([]filterRule, error) {
+	rules, err := cfg.DB.GetFilterRulesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return compileFilterRules(rules), nil
+}
+
+// decodeFilterRule reads a filter rule from the request body. It responds
+// with an error itself and returns false if the rule isn't valid.
+// pattern_type defaults to literal.
+func decodeFilterRule(w http.ResponseWriter, r *http.Request) (database.CreateFilterRuleParams, bool) {
+	type parameters struct {
+		FeedID      *uuid.UUID `json:"feed_id"`
+		Field       string     `json:"field"`
+		PatternType string     `json:"pattern_type"`
+		Pattern     string     `json:"pattern"`
+		Action      string     `json:"action"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return database.CreateFilterRuleParams{}, false
+	}
+	if params.PatternType == "" {
+		params.PatternType = patternLiteral
+	}
+
+	switch params.Field {
+	case ruleFieldTitle, ruleFieldContent, ruleFieldAuthor, ruleFieldCategory:
+	default:
+		respondWithERROR(w, http.StatusBadRequest, "Field must be one of title, content, author or category")
+		return database.CreateFilterRuleParams{}, false
+	}
+	switch params.Action {
+	case ruleActionHide, ruleActionHighlight, ruleActionMarkRead:
+	default:
+		respondWithERROR(w, http.StatusBadRequest, "Action must be one of hide, highlight or mark_read")
+		return database.CreateFilterRuleParams{}, false
+	}
+	if params.Pattern == "" {
+		respondWithERROR(w, http.StatusBadRequest, "Pattern is required")
+		return database.CreateFilterRuleParams{}, false
+	}
+	switch params.PatternType {
+	case patternLiteral:
+	case patternRegex:
+		if _, err := regexp.Compile(params.Pattern); err != nil {
+			respondWithERROR(w, http.StatusBadRequest, "Invalid regular expression: "+err.Error())
+			return database.CreateFilterRuleParams{}, false
+		}
+	default:
+		respondWithERROR(w, http.StatusBadRequest, "Pattern type must be either literal or regex")
+		return database.CreateFilterRuleParams{}, false
+	}
+
+	arg := database.CreateFilterRuleParams{
+		Field:       params.Field,
+		PatternType: params.PatternType,
+		Pattern:     params.Pattern,
+		Action:      params.Action,
+	}
+	if params.FeedID != nil {
+		arg.FeedID = uuid.NullUUID{UUID: *params.FeedID, Valid: true}
+	}
+	return arg, true
+}
@@ -1,28 +1,185 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerPostPost(w http.ResponseWriter, r *http.Request, user database.User) {
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if specifiedLimit, err := strconv.Atoi(limitStr); err == nil {
-		limit = specifiedLimit
+	limit, err := parseLimit(r, 10)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// has_media=true only returns posts with enclosures, e.g. podcast episodes
+	hasMedia, _ := strconv.ParseBool(r.URL.Query().Get("has_media"))
+	// collapse=true returns a single entry per story, listing the posts of
+	// every feed that published it
+	collapse, _ := strconv.ParseBool(r.URL.Query().Get("collapse"))
+
+	params := database.GetPostsForUserParams{
+		UserID:   user.ID,
+		HasMedia: hasMedia,
+		Collapse: collapse,
+		Limit:    int32(limit),
+	}
+	if err := applyPostFilter(r, &params); err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	postList, err := cfg.DB.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get feed follow")
+		return
+	}
+	rules, err := cfg.userFilterRules(r.Context(), user.ID)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get filter rules")
+		return
+	}
+	posts := applyFilterRules(databasePostsToPosts(postList), rules)
+
+	if collapse && len(posts) > 0 {
+		clusterIDs := make([]uuid.UUID, len(posts))
+		for i, post := range posts {
+			clusterIDs[i] = post.ClusterID
+		}
+		members, err := cfg.DB.GetClusterPostsForUser(r.Context(), database.GetClusterPostsForUserParams{
+			UserID:     user.ID,
+			ClusterIds: clusterIDs,
+		})
+		if err != nil {
+			respondWithERROR(w, http.StatusInternalServerError, "Couldn't get post sources")
+			return
+		}
+		sources := map[uuid.UUID][]PostSource{}
+		for _, member := range applyFilterRules(databasePostsToPosts(members), rules) {
+			sources[member.ClusterID] = append(sources[member.ClusterID], PostSource{
+				PostID:      member.ID,
+				FeedID:      member.FeedID,
+				Title:       member.Title,
+				Url:         member.Url,
+				PublishedAt: member.PublishedAt,
+			})
+		}
+		for i := range posts {
+			posts[i].Sources = sources[posts[i].ClusterID]
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, posts)
+
+}
+
+// applyPostFilter reads the feed_id, category_id and category query
+// parameters, which narrow a list of posts down to a single feed, to the feeds
+// filed into one of the user's categories or to the posts tagged with a
+// category. The error it returns says which parameter is invalid.
+func applyPostFilter(r *http.Request, params *database.GetPostsForUserParams) error {
+	var err error
+	params.FeedID, err = parseNullUUID(r.URL.Query().Get("feed_id"))
+	if err != nil {
+		return errors.New("feed_id must be a UUID")
+	}
+	params.CategoryID, err = parseNullUUID(r.URL.Query().Get("category_id"))
+	if err != nil {
+		return errors.New("category_id must be a UUID")
+	}
+	category := r.URL.Query().Get("category")
+	params.Category = sql.NullString{String: category, Valid: category != ""}
+	return nil
+}
+
+// maxLimit caps how many items a list endpoint returns at once.
+const maxLimit = 500
+
+// parseLimit reads the limit query parameter, which defaults to fallback and
+// is capped at maxLimit.
+func parseLimit(r *http.Request, fallback int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit must be a positive integer")
 	}
+	return min(limit, maxLimit), nil
+}
+
+// parseNullUUID parses an optional UUID; an empty string is NULL.
+func parseNullUUID(value string) (uuid.NullUUID, error) {
+	if value == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
 
-	postList, err := cfg.DB.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+// handlerPostPostStar stars a post of a feed the user follows. Starred posts
+// are exempt from the retention policy.
+func (cfg *apiConfig) handlerPostPostStar(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	// Only posts of followed feeds can be starred, so that users can't keep
+	// any post from being pruned
+	follows, err := cfg.DB.UserFollowsPost(r.Context(), database.UserFollowsPostParams{
+		PostID: postID,
 		UserID: user.ID,
-		Limit:  int32(limit),
 	})
 	if err != nil {
-		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get feed follow")
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't star post")
+		return
+	}
+	if !follows {
+		respondWithERROR(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, databasePostsToPosts(postList))
+	err = cfg.DB.StarPost(r.Context(), database.StarPostParams{
+		UserID:    user.ID,
+		PostID:    postID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if database.IsForeignKeyViolation(err) {
+		respondWithERROR(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't star post")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerDeletePostStar(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
 
+	err = cfg.DB.UnstarPost(r.Context(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't unstar post")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
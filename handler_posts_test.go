@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHandlerPostPostCollapse(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+
+		// The same story is published by three feeds, each filed into a
+		// category of its own; z stored it first but is muted
+		feeds := map[string]database.Feed{}
+		categories := map[string]Category{}
+		for _, name := range []string{"x", "y", "z"} {
+			feed, follow := createTestFeed(t, db, api.user.ID, "https://"+name+".example/feed")
+			feeds[name] = feed
+
+			w := api.request(http.MethodPost, "/v1/categories", map[string]string{"name": name})
+			if w.Code != http.StatusCreated {
+				t.Fatalf("POST /v1/categories: status %d: %s", w.Code, w.Body)
+			}
+			categories[name] = decodeBody[Category](t, w)
+			w = api.request(http.MethodPut, "/v1/feed_follows/"+follow.ID.String()+"/category", map[string]uuid.UUID{"category_id": categories[name].ID})
+			if w.Code != http.StatusOK {
+				t.Fatalf("PUT category: status %d: %s", w.Code, w.Body)
+			}
+			if name == "z" {
+				w = api.request(http.MethodPatch, "/v1/feed_follows/"+follow.ID.String(), map[string]bool{"muted": true})
+				if w.Code != http.StatusOK {
+					t.Fatalf("PATCH feed follow: status %d: %s", w.Code, w.Body)
+				}
+			}
+		}
+
+		cluster := uuid.New()
+		stored := time.Now().UTC().Add(-time.Hour)
+		for _, post := range []struct{ feed, tags string }{
+			{"z", `["go"]`},
+			{"x", `[]`},
+			{"y", `["Go"]`},
+		} {
+			params := newTestPost(feeds[post.feed].ID, "https://"+post.feed+".example/story", post.feed)
+			params.ClusterID = cluster
+			params.Categories = json.RawMessage(post.tags)
+			params.CreatedAt = stored
+			stored = stored.Add(time.Minute)
+			storeTestPosts(t, db, params)
+		}
+
+		tests := []struct {
+			name  string
+			query string
+			want  []string
+		}{
+			{"all", "", []string{"x"}},
+			{"feed_id", "&feed_id=" + feeds["y"].ID.String(), []string{"y"}},
+			{"muted feed_id", "&feed_id=" + feeds["z"].ID.String(), []string{"z"}},
+			{"category", "&category=go", []string{"y"}},
+			{"category_id", "&category_id=" + categories["y"].ID.String(), []string{"y"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := api.request(http.MethodGet, "/v1/posts?collapse=true"+tt.query, nil)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d: %s", w.Code, w.Body)
+				}
+				got := postTitles(decodeBody[[]Post](t, w))
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		// The collapsed entry lists the followed feeds that published it
+		w := api.request(http.MethodGet, "/v1/posts?collapse=true", nil)
+		posts := decodeBody[[]Post](t, w)
+		var sources []string
+		for _, source := range posts[0].Sources {
+			sources = append(sources, source.Title)
+		}
+		if want := []string{"x", "y"}; !slices.Equal(sources, want) {
+			t.Errorf("sources = %v, want %v", sources, want)
+		}
+	})
+}
+
+func TestHandlerPostPostBadFilter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+
+		for _, param := range []string{"feed_id", "category_id"} {
+			w := api.request(http.MethodGet, "/v1/posts?"+param+"=nope", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s: status %d, want %d", param, w.Code, http.StatusBadRequest)
+			}
+			body := decodeBody[map[string]string](t, w)
+			if want := param + " must be a UUID"; body["error"] != want {
+				t.Errorf("%s: error %q, want %q", param, body["error"], want)
+			}
+		}
+	})
+}
+
+func TestHandlerPostPostFilterRules(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+		feed, _ := createTestFeed(t, db, api.user.ID, "https://x.example/feed")
+		storeTestPosts(t, db,
+			newTestPost(feed.ID, "https://x.example/1", "Sponsored: a gadget"),
+			newTestPost(feed.ID, "https://x.example/2", "Release notes"),
+			newTestPost(feed.ID, "https://x.example/3", "A walk"),
+		)
+
+		for _, rule := range []map[string]string{
+			{"field": "title", "pattern": "sponsored", "action": "hide"},
+			{"field": "title", "pattern_type": "regex", "pattern": "^Release", "action": "highlight"},
+		} {
+			w := api.request(http.MethodPost, "/v1/filter_rules", rule)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("POST /v1/filter_rules: status %d: %s", w.Code, w.Body)
+			}
+		}
+
+		w := api.request(http.MethodGet, "/v1/posts", nil)
+		posts := decodeBody[[]Post](t, w)
+		titles := postTitles(posts)
+		slices.Sort(titles)
+		if want := []string{"A walk", "Release notes"}; !slices.Equal(titles, want) {
+			t.Fatalf("got %v, want %v", titles, want)
+		}
+		for _, post := range posts {
+			if post.Highlighted != (post.Title == "Release notes") {
+				t.Errorf("%q highlighted = %v", post.Title, post.Highlighted)
+			}
+		}
+	})
+}
+
+func TestHandlerPostPostStar(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+		other := api.newUser("bob")
+		feed, _ := createTestFeed(t, db, api.user.ID, "https://x.example/feed")
+		post := newTestPost(feed.ID, "https://x.example/1", "Post")
+		storeTestPosts(t, db, post)
+
+		tests := []struct {
+			name   string
+			apiKey string
+			postID uuid.UUID
+			want   int
+		}{
+			{"followed post", api.user.ApiKey, post.ID, http.StatusNoContent},
+			{"unknown post", api.user.ApiKey, uuid.New(), http.StatusNotFound},
+			{"post of a feed not followed", other.ApiKey, post.ID, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := api.do(http.MethodPost, "/v1/posts/"+tt.postID.String()+"/star", tt.apiKey, nil)
+				if w.Code != tt.want {
+					t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+				}
+			})
+		}
+	})
+}
+
+func TestHandlerPostPostOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+		feed, _ := createTestFeed(t, db, api.user.ID, "https://x.example/feed")
+
+		// Feeds publish their dates with their own UTC offset
+		paris := time.FixedZone("CEST", 2*60*60)
+		var posts []database.CreatePostParams
+		for _, post := range []struct {
+			title       string
+			publishedAt time.Time
+		}{
+			{"08:00 UTC", time.Date(2024, 6, 1, 10, 0, 0, 0, paris)},
+			{"09:00 UTC", time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)},
+			{"07:30 UTC", time.Date(2024, 6, 1, 9, 30, 0, 0, paris)},
+		} {
+			params := newTestPost(feed.ID, "https://x.example/"+post.title, post.title)
+			params.PublishedAt.Time = post.publishedAt
+			posts = append(posts, params)
+		}
+		storeTestPosts(t, db, posts...)
+
+		w := api.request(http.MethodGet, "/v1/posts", nil)
+		got := postTitles(decodeBody[[]Post](t, w))
+		if want := []string{"09:00 UTC", "08:00 UTC", "07:30 UTC"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
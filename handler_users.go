@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -23,11 +24,17 @@ func (cfg *apiConfig) handlerPostUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	feedToken, err := database.NewToken()
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create user")
+		return
+	}
 	user, err := cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Name:      params.Name,
+		FeedToken: feedToken,
 	})
 
 	if err != nil {
@@ -40,3 +47,102 @@ func (cfg *apiConfig) handlerPostUsers(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) handlerGetUsers(w http.ResponseWriter, r *http.Request, user database.User) {
 	respondWithJSON(w, http.StatusOK, databaseUserToUser(user))
 }
+
+// handlerPostFeedToken gives the user a new feed token, for when the URL of
+// their outbound feeds has leaked.
+func (cfg *apiConfig) handlerPostFeedToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedToken, err := database.NewToken()
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't rotate feed token")
+		return
+	}
+	user, err = cfg.DB.RotateFeedToken(r.Context(), database.RotateFeedTokenParams{
+		FeedToken: feedToken,
+		ID:        user.ID,
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't rotate feed token")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, databaseUserToUser(user))
+}
+
+func (cfg *apiConfig) handlerGetUserFeedAtom(w http.ResponseWriter, r *http.Request) {
+	user, posts, ok := cfg.userFeedPosts(w, r)
+	if !ok {
+		return
+	}
+	respondWithXML(w, http.StatusOK, "application/atom+xml; charset=utf-8", newAtomFeed(user, posts, selfURL(r)))
+}
+
+func (cfg *apiConfig) handlerGetUserFeedRSS(w http.ResponseWriter, r *http.Request) {
+	user, posts, ok := cfg.userFeedPosts(w, r)
+	if !ok {
+		return
+	}
+	respondWithXML(w, http.StatusOK, "application/rss+xml; charset=utf-8", newRSSFeed(user, posts, selfURL(r)))
+}
+
+func (cfg *apiConfig) handlerGetUserFeedJSON(w http.ResponseWriter, r *http.Request) {
+	user, posts, ok := cfg.userFeedPosts(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSONType(w, http.StatusOK, "application/feed+json", newJSONFeed(user, posts, selfURL(r)))
+}
+
+// userFeedPosts returns the user an outbound feed belongs to and their latest
+// posts, optionally narrowed down by feed_id, category_id or category. Feed
+// readers can't send the ApiKey header, so the request is authenticated by
+// the user's feed token in the token query parameter. It responds with an
+// error itself and returns false on failure.
+func (cfg *apiConfig) userFeedPosts(w http.ResponseWriter, r *http.Request) (database.User, []Post, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return database.User{}, nil, false
+	}
+
+	// An unknown user and a wrong token look the same to the caller
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	token := r.URL.Query().Get("token")
+	if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(user.FeedToken)) != 1 {
+		respondWithERROR(w, http.StatusNotFound, "Feed not found")
+		return database.User{}, nil, false
+	}
+
+	limit, err := parseLimit(r, 50)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return database.User{}, nil, false
+	}
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	}
+	if err := applyPostFilter(r, &params); err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return database.User{}, nil, false
+	}
+	postList, err := cfg.DB.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get posts")
+		return database.User{}, nil, false
+	}
+	rules, err := cfg.userFilterRules(r.Context(), user.ID)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get filter rules")
+		return database.User{}, nil, false
+	}
+	return user, applyFilterRules(databasePostsToPosts(postList), rules), true
+}
+
+// selfURL returns the URL a request was made to, without its query string
+// so as not to repeat the feed token in the feed.
+func selfURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
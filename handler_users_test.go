@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+func TestHandlerPostFeedToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+		token := regexp.MustCompile(`^[0-9a-f]{64}$`)
+		if !token.MatchString(api.user.FeedToken) {
+			t.Fatalf("feed token %q isn't 32 random bytes in hex", api.user.FeedToken)
+		}
+		oldToken := api.user.FeedToken
+
+		w := api.request(http.MethodPost, "/v1/users/feed_token", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		user := decodeBody[User](t, w)
+		if !token.MatchString(user.FeedToken) || user.FeedToken == oldToken {
+			t.Fatalf("feed token %q after rotating %q", user.FeedToken, oldToken)
+		}
+
+		feedURL := "/v1/users/" + user.ID.String() + "/feed.json?token="
+		w = api.do(http.MethodGet, feedURL+oldToken, "", nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("old token: status %d, want %d", w.Code, http.StatusNotFound)
+		}
+		w = api.do(http.MethodGet, feedURL+user.FeedToken, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("new token: status %d: %s", w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/feed+json" {
+			t.Errorf("Content-Type = %q, want application/feed+json", got)
+		}
+	})
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{"", 50, false},
+		{"?limit=20", 20, false},
+		{"?limit=100000", maxLimit, false},
+		{"?limit=0", 0, true},
+		{"?limit=-1", 0, true},
+		{"?limit=ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLimit(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil), 50)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseLimit(%q) = %d, %v", tt.query, got, err)
+		}
+	}
+}
+
+func TestHandlersRejectBadLimit(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+		w := api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": "https://hooks.example/blog"})
+		webhook := decodeBody[Webhook](t, w)
+
+		for _, path := range []string{
+			"/v1/users/" + api.user.ID.String() + "/feed.atom?token=" + api.user.FeedToken + "&limit=-1",
+			"/v1/webhooks/" + webhook.ID.String() + "/deliveries?limit=-1",
+			"/v1/posts?limit=0",
+		} {
+			w := api.request(http.MethodGet, path, nil)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want %d", path, w.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
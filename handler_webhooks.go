@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerPostWebhooks registers a URL the new posts of the feeds the user
+// follows are posted to, only those of feed_id or of the feeds filed into
+// category_id if either is set. Only this response carries the secret the
+// payloads are signed with.
+func (cfg *apiConfig) handlerPostWebhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Url        string     `json:"url"`
+		FeedID     *uuid.UUID `json:"feed_id"`
+		CategoryID *uuid.UUID `json:"category_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	target, err := url.Parse(params.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		respondWithERROR(w, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		return
+	}
+
+	arg := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		Url:       target.String(),
+	}
+	if params.FeedID != nil {
+		arg.FeedID = uuid.NullUUID{UUID: *params.FeedID, Valid: true}
+	}
+	if params.CategoryID != nil {
+		owned, err := cfg.ownsCategory(r, user, *params.CategoryID)
+		if err != nil {
+			respondWithERROR(w, http.StatusInternalServerError, "Couldn't get categories")
+			return
+		}
+		if !owned {
+			respondWithERROR(w, http.StatusNotFound, "Couldn't find category")
+			return
+		}
+		arg.CategoryID = uuid.NullUUID{UUID: *params.CategoryID, Valid: true}
+	}
+	arg.Secret, err = database.NewToken()
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create webhook")
+		return
+	}
+
+	webhook, err := cfg.DB.CreateWebhook(r.Context(), arg)
+	if database.IsForeignKeyViolation(err) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't create webhook")
+		return
+	}
+
+	result := databaseWebhookToWebhook(webhook)
+	result.Secret = webhook.Secret
+	respondWithJSON(w, http.StatusCreated, result)
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhooks, err := cfg.DB.GetWebhooksForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get webhooks")
+		return
+	}
+
+	result := make([]Webhook, len(webhooks))
+	for i, webhook := range webhooks {
+		result[i] = databaseWebhookToWebhook(webhook)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerDeleteWebhook deletes a webhook of the user along with its
+// delivery log. Pending deliveries are dropped.
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+		ID:     webhookID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't delete webhook")
+		return
+	}
+	if deleted == 0 {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetWebhookDeliveries returns the delivery log of a webhook of the
+// user, most recent first.
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	limit, err := parseLimit(r, 50)
+	if err != nil {
+		respondWithERROR(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := cfg.DB.GetWebhookByID(r.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && webhook.UserID != user.ID) {
+		respondWithERROR(w, http.StatusNotFound, "Couldn't find webhook")
+		return
+	}
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get webhook")
+		return
+	}
+
+	deliveries, err := cfg.DB.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: webhook.ID,
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		respondWithERROR(w, http.StatusInternalServerError, "Couldn't get webhook deliveries")
+		return
+	}
+
+	result := make([]WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		result[i] = databaseWebhookDeliveryToWebhookDelivery(delivery)
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// ownsCategory reports whether the category belongs to the user.
+func (cfg *apiConfig) ownsCategory(r *http.Request, user database.User, categoryID uuid.UUID) (bool, error) {
+	categories, err := cfg.DB.GetCategoriesForUser(r.Context(), user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, category := range categories {
+		if category.ID == categoryID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+func TestHandlerPostWebhooks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		api := newTestAPI(t, db)
+
+		for _, url := range []string{"", "ftp://hooks.example/", "/relative"} {
+			w := api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": url})
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("url %q: status %d, want %d", url, w.Code, http.StatusBadRequest)
+			}
+		}
+
+		w := api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": "https://hooks.example/blog"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		created := decodeBody[Webhook](t, w)
+		if len(created.Secret) != 64 {
+			t.Errorf("secret %q isn't 32 random bytes in hex", created.Secret)
+		}
+
+		// The secret is only shown once
+		w = api.request(http.MethodGet, "/v1/webhooks", nil)
+		webhooks := decodeBody[[]map[string]any](t, w)
+		if len(webhooks) != 1 {
+			t.Fatalf("got %d webhooks, want 1", len(webhooks))
+		}
+		if secret, ok := webhooks[0]["secret"]; ok {
+			t.Errorf("listing returned the secret %q", secret)
+		}
+	})
+}
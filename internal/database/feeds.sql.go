@@ -7,24 +7,28 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createFeed = `-- name: CreateFeed :one
-insert into feeds (id, created_at, updated_at, name, url, user_id)
-values ($1, $2, $3, $4, $5, $6)
-returning id, created_at, updated_at, name, url, user_id, last_fetched_at
+insert into feeds (id, created_at, updated_at, name, url, user_id, request_headers, canonical_url)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+returning id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	RequestHeaders json.RawMessage
+	CanonicalUrl   string
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -35,6 +39,8 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.RequestHeaders,
+		arg.CanonicalUrl,
 	)
 	var i Feed
 	err := row.Scan(
@@ -45,6 +51,12 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
 	)
 	return i, err
 }
@@ -53,7 +65,7 @@ const createFeedFollow = `-- name: CreateFeedFollow :one
 
 insert into feed_follows (id, created_at, updated_at, user_id, feed_id)
 values ($1, $2, $3, $4, $5)
-returning id, created_at, updated_at, user_id, feed_id
+returning id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
 `
 
 type CreateFeedFollowParams struct {
@@ -79,10 +91,39 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
 	)
 	return i, err
 }
 
+const createFeedURLAlias = `-- name: CreateFeedURLAlias :exec
+INSERT INTO feed_url_aliases (url, feed_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id
+`
+
+type CreateFeedURLAliasParams struct {
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) CreateFeedURLAlias(ctx context.Context, arg CreateFeedURLAliasParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedURLAlias, arg.Url, arg.FeedID)
+	return err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
 
 delete from feed_follows where id = $1 and user_id = $2
@@ -98,26 +139,86 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.locked_by, feeds.locked_until, feeds.request_headers, feeds.status, feeds.not_found_since, feeds.canonical_url FROM feeds
+WHERE feeds.url = $1
+OR feeds.canonical_url = $2
+OR feeds.id = (SELECT aliases.feed_id FROM feed_url_aliases aliases WHERE aliases.url = $1)
+LIMIT 1
+`
+
+type GetFeedByURLParams struct {
+	Url          string
+	CanonicalUrl string
+}
+
+func (q *Queries) GetFeedByURL(ctx context.Context, arg GetFeedByURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, arg.Url, arg.CanonicalUrl)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 
-select id, created_at, updated_at, user_id, feed_id from feed_follows where user_id = $1
+select feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.category_id, feed_follows.custom_title, feed_follows.muted, feed_follows.notify, feeds.name as feed_name, feeds.status as feed_status, categories.name as category_name
+from feed_follows
+join feeds on feeds.id = feed_follows.feed_id
+left join categories on categories.id = feed_follows.category_id
+where feed_follows.user_id = $1
 `
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]FeedFollow, error) {
+type GetFeedFollowsForUserRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	FeedID       uuid.UUID
+	CategoryID   uuid.NullUUID
+	CustomTitle  sql.NullString
+	Muted        bool
+	Notify       string
+	FeedName     string
+	FeedStatus   string
+	CategoryName sql.NullString
+}
+
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
 	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []FeedFollow
+	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
-		var i FeedFollow
+		var i GetFeedFollowsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.CategoryID,
+			&i.CustomTitle,
+			&i.Muted,
+			&i.Notify,
+			&i.FeedName,
+			&i.FeedStatus,
+			&i.CategoryName,
 		); err != nil {
 			return nil, err
 		}
@@ -134,7 +235,7 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 
 const getFeeds = `-- name: GetFeeds :many
 
-select id, created_at, updated_at, name, url, user_id, last_fetched_at from feeds
+select id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url from feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -154,6 +255,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.LockedBy,
+			&i.LockedUntil,
+			&i.RequestHeaders,
+			&i.Status,
+			&i.NotFoundSince,
+			&i.CanonicalUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -170,13 +277,28 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
 
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT $1
+UPDATE feeds
+SET locked_by = $1::text,
+locked_until = NOW() + make_interval(secs => $2::float8)
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE status NOT IN ('paused', 'gone')
+    AND (locked_until IS NULL OR locked_until < NOW())
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
 `
 
-func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
+type GetNextFeedsToFetchParams struct {
+	LockedBy     string
+	LeaseSeconds float64
+	MaxFeeds     int32
+}
+
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.LockedBy, arg.LeaseSeconds, arg.MaxFeeds)
 	if err != nil {
 		return nil, err
 	}
@@ -192,6 +314,12 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.LockedBy,
+			&i.LockedUntil,
+			&i.RequestHeaders,
+			&i.Status,
+			&i.NotFoundSince,
+			&i.CanonicalUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -209,13 +337,317 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 const markFeedFetched = `-- name: MarkFeedFetched :one
 UPDATE feeds
 SET last_fetched_at = NOW(),
+updated_at = NOW(),
+locked_by = NULL,
+locked_until = NULL
+WHERE id = $1 AND locked_by = $2::text
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type MarkFeedFetchedParams struct {
+	ID       uuid.UUID
+	LockedBy string
+}
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFetched, arg.ID, arg.LockedBy)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const markFeedGone = `-- name: MarkFeedGone :exec
+UPDATE feeds
+SET status = 'gone',
+updated_at = NOW()
+WHERE id = $1
+AND status <> 'paused'
+`
+
+// A feed paused while it was being fetched stays paused.
+func (q *Queries) MarkFeedGone(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedGone, id)
+	return err
+}
+
+const markFeedHealthy = `-- name: MarkFeedHealthy :exec
+UPDATE feeds
+SET status = 'active',
+not_found_since = NULL,
 updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+AND (status = 'broken' OR not_found_since IS NOT NULL)
+AND status <> 'paused'
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, markFeedFetched, id)
+// A feed paused while it was being fetched stays paused.
+func (q *Queries) MarkFeedHealthy(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedHealthy, id)
+	return err
+}
+
+const markFeedNotFound = `-- name: MarkFeedNotFound :one
+UPDATE feeds
+SET not_found_since = COALESCE(not_found_since, NOW()),
+status = CASE
+    WHEN not_found_since < NOW() - make_interval(secs => $1::float8) THEN 'broken'
+    ELSE status
+END,
+updated_at = NOW()
+WHERE id = $2
+AND status <> 'paused'
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type MarkFeedNotFoundParams struct {
+	BrokenAfterSeconds float64
+	ID                 uuid.UUID
+}
+
+// Returns no rows if the feed was paused while it was being fetched.
+func (q *Queries) MarkFeedNotFound(ctx context.Context, arg MarkFeedNotFoundParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedNotFound, arg.BrokenAfterSeconds, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1,
+updated_at = NOW()
+WHERE feed_follows.feed_id = $2
+AND feed_follows.user_id NOT IN (
+    SELECT target.user_id FROM feed_follows target WHERE target.feed_id = $1
+)
+`
+
+type MoveFeedFollowsParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const moveFeedURLAliases = `-- name: MoveFeedURLAliases :exec
+UPDATE feed_url_aliases
+SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MoveFeedURLAliasesParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedURLAliases(ctx context.Context, arg MoveFeedURLAliasesParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedURLAliases, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const setFeedFollowCategory = `-- name: SetFeedFollowCategory :one
+
+update feed_follows
+set category_id = $1,
+updated_at = NOW()
+where feed_follows.id = $2 and feed_follows.user_id = $3
+and ($1::uuid is null or exists (
+    select 1 from categories c
+    where c.id = $1 and c.user_id = $3
+))
+returning id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
+`
+
+type SetFeedFollowCategoryParams struct {
+	CategoryID uuid.NullUUID
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+// Files a follow into a category of the same user, or takes it out of its
+// category if category_id is NULL. Returns no rows if either doesn't belong
+// to the user.
+func (q *Queries) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowCategory, arg.CategoryID, arg.ID, arg.UserID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const setFeedStatus = `-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = $3,
+not_found_since = NULL,
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type SetFeedStatusParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Status string
+}
+
+func (q *Queries) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedStatus, arg.ID, arg.UserID, arg.Status)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const updateFeedFollow = `-- name: UpdateFeedFollow :one
+
+update feed_follows
+set custom_title = nullif(coalesce($1::text, custom_title), ''),
+muted = coalesce($2::bool, muted),
+notify = coalesce($3::text, notify),
+updated_at = NOW()
+where id = $4 and user_id = $5
+returning id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
+`
+
+type UpdateFeedFollowParams struct {
+	CustomTitle sql.NullString
+	Muted       sql.NullBool
+	Notify      sql.NullString
+	ID          uuid.UUID
+	UserID      uuid.UUID
+}
+
+// Changes the settings of a follow; NULL leaves a setting as is and an empty
+// custom_title removes it.
+func (q *Queries) UpdateFeedFollow(ctx context.Context, arg UpdateFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedFollow,
+		arg.CustomTitle,
+		arg.Muted,
+		arg.Notify,
+		arg.ID,
+		arg.UserID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const updateFeedRequestHeaders = `-- name: UpdateFeedRequestHeaders :one
+
+update feeds
+set request_headers = $3,
+updated_at = NOW()
+where id = $1 and user_id = $2
+returning id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type UpdateFeedRequestHeadersParams struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	RequestHeaders json.RawMessage
+}
+
+func (q *Queries) UpdateFeedRequestHeaders(ctx context.Context, arg UpdateFeedRequestHeadersParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedRequestHeaders, arg.ID, arg.UserID, arg.RequestHeaders)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $2,
+canonical_url = $3,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type UpdateFeedURLParams struct {
+	ID           uuid.UUID
+	Url          string
+	CanonicalUrl string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedURL, arg.ID, arg.Url, arg.CanonicalUrl)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -225,6 +657,12 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, erro
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
 	)
 	return i, err
 }
@@ -0,0 +1,1518 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-memory Store meant for tests. It mirrors the
+// constraints and cascades of the Postgres schema closely enough for the
+// handlers and the scraper to behave the same way on top of it.
+type MemoryStore struct {
+	// txMu serializes transactions
+	txMu sync.Mutex
+
+	mu          sync.Mutex
+	users       map[uuid.UUID]User
+	feeds       map[uuid.UUID]Feed
+	feedFollows map[uuid.UUID]FeedFollow
+	categories  map[uuid.UUID]Category
+	filterRules map[uuid.UUID]FilterRule
+	feedAliases map[string]uuid.UUID
+	posts       map[uuid.UUID]Post
+	postStars   map[postStar]time.Time
+	ruleMatches map[ruleMatch]time.Time
+	webhooks    map[uuid.UUID]Webhook
+	deliveries  map[uuid.UUID]WebhookDelivery
+}
+
+// postStar is the primary key of post_stars.
+type postStar struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+// ruleMatch is the primary key of post_rule_matches.
+type ruleMatch struct {
+	RuleID uuid.UUID
+	PostID uuid.UUID
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[uuid.UUID]User{},
+		feeds:       map[uuid.UUID]Feed{},
+		feedFollows: map[uuid.UUID]FeedFollow{},
+		categories:  map[uuid.UUID]Category{},
+		filterRules: map[uuid.UUID]FilterRule{},
+		feedAliases: map[string]uuid.UUID{},
+		posts:       map[uuid.UUID]Post{},
+		postStars:   map[postStar]time.Time{},
+		ruleMatches: map[ruleMatch]time.Time{},
+		webhooks:    map[uuid.UUID]Webhook{},
+		deliveries:  map[uuid.UUID]WebhookDelivery{},
+	}
+}
+
+// Ping always succeeds since there is no database to reach.
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// memoryState is a copy of the store's tables.
+type memoryState struct {
+	users       map[uuid.UUID]User
+	feeds       map[uuid.UUID]Feed
+	feedFollows map[uuid.UUID]FeedFollow
+	categories  map[uuid.UUID]Category
+	filterRules map[uuid.UUID]FilterRule
+	feedAliases map[string]uuid.UUID
+	posts       map[uuid.UUID]Post
+	postStars   map[postStar]time.Time
+	ruleMatches map[ruleMatch]time.Time
+	webhooks    map[uuid.UUID]Webhook
+	deliveries  map[uuid.UUID]WebhookDelivery
+}
+
+func copyMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func (m *MemoryStore) snapshot() memoryState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return memoryState{
+		users:       copyMap(m.users),
+		feeds:       copyMap(m.feeds),
+		feedFollows: copyMap(m.feedFollows),
+		categories:  copyMap(m.categories),
+		filterRules: copyMap(m.filterRules),
+		feedAliases: copyMap(m.feedAliases),
+		posts:       copyMap(m.posts),
+		postStars:   copyMap(m.postStars),
+		ruleMatches: copyMap(m.ruleMatches),
+		webhooks:    copyMap(m.webhooks),
+		deliveries:  copyMap(m.deliveries),
+	}
+}
+
+func (m *MemoryStore) restore(state memoryState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = state.users
+	m.feeds = state.feeds
+	m.feedFollows = state.feedFollows
+	m.categories = state.categories
+	m.filterRules = state.filterRules
+	m.feedAliases = state.feedAliases
+	m.posts = state.posts
+	m.postStars = state.postStars
+	m.ruleMatches = state.ruleMatches
+	m.webhooks = state.webhooks
+	m.deliveries = state.deliveries
+}
+
+// memoryTx is the Store handed to an InTx callback, so that nested calls to
+// InTx join the running transaction.
+type memoryTx struct {
+	*MemoryStore
+}
+
+func (tx memoryTx) InTx(ctx context.Context, fn func(Store) error) error {
+	return fn(tx)
+}
+
+// InTx runs fn and rolls back everything it did if it returns an error.
+// Transactions are serialized with each other but, unlike in a database,
+// aren't isolated from operations made outside of a transaction.
+func (m *MemoryStore) InTx(ctx context.Context, fn func(Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	state := m.snapshot()
+	err := fn(memoryTx{m})
+	if err != nil {
+		m.restore(state)
+	}
+	return err
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.ID]; ok {
+		return User{}, ErrUniqueViolation
+	}
+	apiKey, err := NewToken()
+	if err != nil {
+		return User{}, err
+	}
+	user := User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		ApiKey:    apiKey,
+		FeedToken: arg.FeedToken,
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *MemoryStore) GetUserByApiKey(ctx context.Context, apiKey string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.ApiKey == apiKey {
+			return user, nil
+		}
+	}
+	return User{}, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *MemoryStore) RotateFeedToken(ctx context.Context, arg RotateFeedTokenParams) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return User{}, sql.ErrNoRows
+	}
+	user.FeedToken = arg.FeedToken
+	user.UpdatedAt = time.Now()
+	m.users[arg.ID] = user
+	return user, nil
+}
+
+func (m *MemoryStore) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.ID]; ok {
+		return Feed{}, ErrUniqueViolation
+	}
+	if _, ok := m.feedByURL(arg.Url, arg.CanonicalUrl); ok {
+		return Feed{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return Feed{}, ErrForeignKeyViolation
+	}
+	requestHeaders := arg.RequestHeaders
+	if requestHeaders == nil {
+		requestHeaders = json.RawMessage("{}")
+	}
+	feed := Feed{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Name:           arg.Name,
+		Url:            arg.Url,
+		UserID:         arg.UserID,
+		RequestHeaders: requestHeaders,
+		Status:         "active",
+		CanonicalUrl:   arg.CanonicalUrl,
+	}
+	m.feeds[feed.ID] = feed
+	return feed, nil
+}
+
+func (m *MemoryStore) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteFeed(id)
+	return nil
+}
+
+// deleteFeed removes a feed along with the rows that reference it, like the
+// ON DELETE CASCADE foreign keys do.
+func (m *MemoryStore) deleteFeed(id uuid.UUID) {
+	delete(m.feeds, id)
+	for followID, follow := range m.feedFollows {
+		if follow.FeedID == id {
+			delete(m.feedFollows, followID)
+		}
+	}
+	for postID, post := range m.posts {
+		if post.FeedID == id {
+			m.deletePost(postID)
+		}
+	}
+	for url, feedID := range m.feedAliases {
+		if feedID == id {
+			delete(m.feedAliases, url)
+		}
+	}
+	for ruleID, rule := range m.filterRules {
+		if rule.FeedID.Valid && rule.FeedID.UUID == id {
+			m.deleteFilterRule(ruleID)
+		}
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.FeedID.Valid && webhook.FeedID.UUID == id {
+			m.deleteWebhook(webhookID)
+		}
+	}
+}
+
+func (m *MemoryStore) deletePost(id uuid.UUID) {
+	delete(m.posts, id)
+	for star := range m.postStars {
+		if star.PostID == id {
+			delete(m.postStars, star)
+		}
+	}
+	for match := range m.ruleMatches {
+		if match.PostID == id {
+			delete(m.ruleMatches, match)
+		}
+	}
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.PostID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+func (m *MemoryStore) deleteWebhook(id uuid.UUID) {
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+func (m *MemoryStore) deleteFilterRule(id uuid.UUID) {
+	delete(m.filterRules, id)
+	for match := range m.ruleMatches {
+		if match.RuleID == id {
+			delete(m.ruleMatches, match)
+		}
+	}
+}
+
+// isHidden reports whether a hide rule of the user matched the post.
+func (m *MemoryStore) isHidden(userID, postID uuid.UUID) bool {
+	for match := range m.ruleMatches {
+		rule := m.filterRules[match.RuleID]
+		if match.PostID == postID && rule.UserID == userID && rule.Action == "hide" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) isStarred(postID uuid.UUID) bool {
+	for star := range m.postStars {
+		if star.PostID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) feedByURL(url, canonicalURL string) (Feed, bool) {
+	for _, feed := range m.feeds {
+		if feed.Url == url || feed.CanonicalUrl == canonicalURL {
+			return feed, true
+		}
+	}
+	return Feed{}, false
+}
+
+func (m *MemoryStore) GetFeedByURL(ctx context.Context, arg GetFeedByURLParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if feed, ok := m.feedByURL(arg.Url, arg.CanonicalUrl); ok {
+		return feed, nil
+	}
+	if feedID, ok := m.feedAliases[arg.Url]; ok {
+		return m.feeds[feedID], nil
+	}
+	return Feed{}, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetFeeds(ctx context.Context) ([]Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var feeds []Feed
+	for _, feed := range m.feeds {
+		feeds = append(feeds, feed)
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].CreatedAt.Before(feeds[j].CreatedAt)
+	})
+	return feeds, nil
+}
+
+func (m *MemoryStore) CreateFeedURLAlias(ctx context.Context, arg CreateFeedURLAliasParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.FeedID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	m.feedAliases[arg.Url] = arg.FeedID
+	return nil
+}
+
+func (m *MemoryStore) MoveFeedURLAliases(ctx context.Context, arg MoveFeedURLAliasesParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.TargetFeedID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for url, feedID := range m.feedAliases {
+		if feedID == arg.SourceFeedID {
+			m.feedAliases[url] = arg.TargetFeedID
+		}
+	}
+	return nil
+}
+
+// updateFeed applies fn to the feed with the given id and stores the result.
+// It returns sql.ErrNoRows if there's no such feed or fn reports no match.
+func (m *MemoryStore) updateFeed(id uuid.UUID, fn func(feed *Feed) bool) (Feed, error) {
+	feed, ok := m.feeds[id]
+	if !ok || !fn(&feed) {
+		return Feed{}, sql.ErrNoRows
+	}
+	m.feeds[id] = feed
+	return feed, nil
+}
+
+func (m *MemoryStore) UpdateFeedRequestHeaders(ctx context.Context, arg UpdateFeedRequestHeadersParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateFeed(arg.ID, func(feed *Feed) bool {
+		if feed.UserID != arg.UserID {
+			return false
+		}
+		feed.RequestHeaders = arg.RequestHeaders
+		feed.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+func (m *MemoryStore) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.feeds {
+		if other.ID != arg.ID && (other.Url == arg.Url || other.CanonicalUrl == arg.CanonicalUrl) {
+			return Feed{}, ErrUniqueViolation
+		}
+	}
+	return m.updateFeed(arg.ID, func(feed *Feed) bool {
+		feed.Url = arg.Url
+		feed.CanonicalUrl = arg.CanonicalUrl
+		feed.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+func (m *MemoryStore) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateFeed(arg.ID, func(feed *Feed) bool {
+		if feed.UserID != arg.UserID {
+			return false
+		}
+		feed.Status = arg.Status
+		feed.NotFoundSince = sql.NullTime{}
+		feed.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+func (m *MemoryStore) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var candidates []Feed
+	for _, feed := range m.feeds {
+		if feed.Status == "paused" || feed.Status == "gone" {
+			continue
+		}
+		if feed.LockedUntil.Valid && !feed.LockedUntil.Time.Before(now) {
+			continue
+		}
+		candidates = append(candidates, feed)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].LastFetchedAt, candidates[j].LastFetchedAt
+		if !a.Valid || !b.Valid {
+			return !a.Valid && b.Valid
+		}
+		return a.Time.Before(b.Time)
+	})
+	if len(candidates) > int(arg.MaxFeeds) {
+		candidates = candidates[:arg.MaxFeeds]
+	}
+
+	leaseUntil := now.Add(time.Duration(arg.LeaseSeconds * float64(time.Second)))
+	for i := range candidates {
+		candidates[i].LockedBy = sql.NullString{String: arg.LockedBy, Valid: true}
+		candidates[i].LockedUntil = sql.NullTime{Time: leaseUntil, Valid: true}
+		m.feeds[candidates[i].ID] = candidates[i]
+	}
+	return candidates, nil
+}
+
+func (m *MemoryStore) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateFeed(arg.ID, func(feed *Feed) bool {
+		if !feed.LockedBy.Valid || feed.LockedBy.String != arg.LockedBy {
+			return false
+		}
+		now := time.Now()
+		feed.LastFetchedAt = sql.NullTime{Time: now, Valid: true}
+		feed.UpdatedAt = now
+		feed.LockedBy = sql.NullString{}
+		feed.LockedUntil = sql.NullTime{}
+		return true
+	})
+}
+
+func (m *MemoryStore) MarkFeedGone(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateFeed(id, func(feed *Feed) bool {
+		if feed.Status == "paused" {
+			return false
+		}
+		feed.Status = "gone"
+		feed.UpdatedAt = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (m *MemoryStore) MarkFeedHealthy(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateFeed(id, func(feed *Feed) bool {
+		if feed.Status == "paused" || feed.Status != "broken" && !feed.NotFoundSince.Valid {
+			return false
+		}
+		feed.Status = "active"
+		feed.NotFoundSince = sql.NullTime{}
+		feed.UpdatedAt = time.Now()
+		return true
+	})
+	return nil
+}
+
+func (m *MemoryStore) MarkFeedNotFound(ctx context.Context, arg MarkFeedNotFoundParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateFeed(arg.ID, func(feed *Feed) bool {
+		if feed.Status == "paused" {
+			return false
+		}
+		now := time.Now()
+		brokenAfter := time.Duration(arg.BrokenAfterSeconds * float64(time.Second))
+		if feed.NotFoundSince.Valid && feed.NotFoundSince.Time.Before(now.Add(-brokenAfter)) {
+			feed.Status = "broken"
+		}
+		if !feed.NotFoundSince.Valid {
+			feed.NotFoundSince = sql.NullTime{Time: now, Valid: true}
+		}
+		feed.UpdatedAt = now
+		return true
+	})
+}
+
+func (m *MemoryStore) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feedFollows[arg.ID]; ok {
+		return FeedFollow{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return FeedFollow{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.feeds[arg.FeedID]; !ok {
+		return FeedFollow{}, ErrForeignKeyViolation
+	}
+	for _, follow := range m.feedFollows {
+		if follow.UserID == arg.UserID && follow.FeedID == arg.FeedID {
+			return FeedFollow{}, ErrUniqueViolation
+		}
+	}
+	follow := FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+		Notify:    "all",
+	}
+	m.feedFollows[follow.ID] = follow
+	return follow, nil
+}
+
+func (m *MemoryStore) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if follow, ok := m.feedFollows[arg.ID]; ok && follow.UserID == arg.UserID {
+		delete(m.feedFollows, arg.ID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []GetFeedFollowsForUserRow
+	for _, follow := range m.feedFollows {
+		if follow.UserID != userID {
+			continue
+		}
+		rows = append(rows, GetFeedFollowsForUserRow{
+			ID:           follow.ID,
+			CreatedAt:    follow.CreatedAt,
+			UpdatedAt:    follow.UpdatedAt,
+			UserID:       follow.UserID,
+			FeedID:       follow.FeedID,
+			CategoryID:   follow.CategoryID,
+			CustomTitle:  follow.CustomTitle,
+			Muted:        follow.Muted,
+			Notify:       follow.Notify,
+			FeedName:     m.feeds[follow.FeedID].Name,
+			FeedStatus:   m.feeds[follow.FeedID].Status,
+			CategoryName: m.categoryName(follow.CategoryID),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+	})
+	return rows, nil
+}
+
+func (m *MemoryStore) categoryName(id uuid.NullUUID) sql.NullString {
+	category, ok := m.categories[id.UUID]
+	if !id.Valid || !ok {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: category.Name, Valid: true}
+}
+
+func (m *MemoryStore) UpdateFeedFollow(ctx context.Context, arg UpdateFeedFollowParams) (FeedFollow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follow, ok := m.feedFollows[arg.ID]
+	if !ok || follow.UserID != arg.UserID {
+		return FeedFollow{}, sql.ErrNoRows
+	}
+	if arg.CustomTitle.Valid {
+		follow.CustomTitle = sql.NullString{String: arg.CustomTitle.String, Valid: arg.CustomTitle.String != ""}
+	}
+	if arg.Muted.Valid {
+		follow.Muted = arg.Muted.Bool
+	}
+	if arg.Notify.Valid {
+		follow.Notify = arg.Notify.String
+	}
+	follow.UpdatedAt = time.Now()
+	m.feedFollows[follow.ID] = follow
+	return follow, nil
+}
+
+func (m *MemoryStore) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (FeedFollow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follow, ok := m.feedFollows[arg.ID]
+	if !ok || follow.UserID != arg.UserID {
+		return FeedFollow{}, sql.ErrNoRows
+	}
+	if arg.CategoryID.Valid {
+		category, ok := m.categories[arg.CategoryID.UUID]
+		if !ok || category.UserID != arg.UserID {
+			return FeedFollow{}, sql.ErrNoRows
+		}
+	}
+	follow.CategoryID = arg.CategoryID
+	follow.UpdatedAt = time.Now()
+	m.feedFollows[follow.ID] = follow
+	return follow, nil
+}
+
+// categoryNameTaken mimics the unique (user_id, name) constraint.
+func (m *MemoryStore) categoryNameTaken(userID uuid.UUID, name string, except uuid.UUID) bool {
+	for _, category := range m.categories {
+		if category.UserID == userID && category.Name == name && category.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.categories[arg.ID]; ok {
+		return Category{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return Category{}, ErrForeignKeyViolation
+	}
+	if m.categoryNameTaken(arg.UserID, arg.Name, arg.ID) {
+		return Category{}, ErrUniqueViolation
+	}
+	category := Category(arg)
+	m.categories[category.ID] = category
+	return category, nil
+}
+
+func (m *MemoryStore) GetCategoriesForUser(ctx context.Context, userID uuid.UUID) ([]Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var categories []Category
+	for _, category := range m.categories {
+		if category.UserID == userID {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (m *MemoryStore) RenameCategory(ctx context.Context, arg RenameCategoryParams) (Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, ok := m.categories[arg.ID]
+	if !ok || category.UserID != arg.UserID {
+		return Category{}, sql.ErrNoRows
+	}
+	if m.categoryNameTaken(arg.UserID, arg.Name, arg.ID) {
+		return Category{}, ErrUniqueViolation
+	}
+	category.Name = arg.Name
+	category.UpdatedAt = time.Now()
+	m.categories[category.ID] = category
+	return category, nil
+}
+
+// DeleteCategory takes the follows filed into the category out of it, like
+// ON DELETE SET NULL, and deletes the webhooks scoped to it.
+func (m *MemoryStore) DeleteCategory(ctx context.Context, arg DeleteCategoryParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, ok := m.categories[arg.ID]
+	if !ok || category.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(m.categories, arg.ID)
+	for id, follow := range m.feedFollows {
+		if follow.CategoryID.Valid && follow.CategoryID.UUID == arg.ID {
+			follow.CategoryID = uuid.NullUUID{}
+			m.feedFollows[id] = follow
+		}
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.CategoryID.Valid && webhook.CategoryID.UUID == arg.ID {
+			m.deleteWebhook(webhookID)
+		}
+	}
+	return 1, nil
+}
+
+func (m *MemoryStore) CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.filterRules[arg.ID]; ok {
+		return FilterRule{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return FilterRule{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+		return FilterRule{}, ErrForeignKeyViolation
+	}
+	rule := FilterRule(arg)
+	m.filterRules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *MemoryStore) GetFilterRulesForUser(ctx context.Context, userID uuid.UUID) ([]FilterRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rules []FilterRule
+	for _, rule := range m.filterRules {
+		if rule.UserID == userID {
+			rules = append(rules, rule)
+		}
+	}
+	sortFilterRules(rules)
+	return rules, nil
+}
+
+func (m *MemoryStore) UpdateFilterRule(ctx context.Context, arg UpdateFilterRuleParams) (FilterRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.filterRules[arg.ID]
+	if !ok || rule.UserID != arg.UserID {
+		return FilterRule{}, sql.ErrNoRows
+	}
+	if _, ok := m.feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+		return FilterRule{}, ErrForeignKeyViolation
+	}
+	rule.FeedID = arg.FeedID
+	rule.Field = arg.Field
+	rule.PatternType = arg.PatternType
+	rule.Pattern = arg.Pattern
+	rule.Action = arg.Action
+	rule.UpdatedAt = time.Now()
+	m.filterRules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *MemoryStore) DeleteFilterRule(ctx context.Context, arg DeleteFilterRuleParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.filterRules[arg.ID]
+	if !ok || rule.UserID != arg.UserID {
+		return 0, nil
+	}
+	m.deleteFilterRule(arg.ID)
+	return 1, nil
+}
+
+func (m *MemoryStore) GetHideRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followers := map[uuid.UUID]bool{}
+	for _, follow := range m.feedFollows {
+		if follow.FeedID == feedID {
+			followers[follow.UserID] = true
+		}
+	}
+	var rules []FilterRule
+	for _, rule := range m.filterRules {
+		if !followers[rule.UserID] || rule.Action != "hide" {
+			continue
+		}
+		if rule.FeedID.Valid && rule.FeedID.UUID != feedID {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sortFilterRules(rules)
+	return rules, nil
+}
+
+func sortFilterRules(rules []FilterRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID.String() < rules[j].ID.String()
+	})
+}
+
+func (m *MemoryStore) CreatePostRuleMatch(ctx context.Context, arg CreatePostRuleMatchParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.createPostRuleMatch(arg)
+	return nil
+}
+
+func (m *MemoryStore) CreatePostRuleMatches(ctx context.Context, matches []CreatePostRuleMatchParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, match := range matches {
+		m.createPostRuleMatch(match)
+	}
+	return nil
+}
+
+func (m *MemoryStore) createPostRuleMatch(arg CreatePostRuleMatchParams) {
+	if _, ok := m.filterRules[arg.RuleID]; !ok {
+		return
+	}
+	if _, ok := m.posts[arg.PostID]; !ok {
+		return
+	}
+	key := ruleMatch{RuleID: arg.RuleID, PostID: arg.PostID}
+	if _, ok := m.ruleMatches[key]; !ok {
+		m.ruleMatches[key] = arg.CreatedAt
+	}
+}
+
+func (m *MemoryStore) DeletePostRuleMatches(ctx context.Context, ruleID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for match := range m.ruleMatches {
+		if match.RuleID == ruleID {
+			delete(m.ruleMatches, match)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[arg.ID]; ok {
+		return Webhook{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return Webhook{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.feeds[arg.FeedID.UUID]; arg.FeedID.Valid && !ok {
+		return Webhook{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.categories[arg.CategoryID.UUID]; arg.CategoryID.Valid && !ok {
+		return Webhook{}, ErrForeignKeyViolation
+	}
+	webhook := Webhook(arg)
+	m.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (m *MemoryStore) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var webhooks []Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sortWebhooks(webhooks)
+	return webhooks, nil
+}
+
+func (m *MemoryStore) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, sql.ErrNoRows
+	}
+	return webhook, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[arg.ID]
+	if !ok || webhook.UserID != arg.UserID {
+		return 0, nil
+	}
+	m.deleteWebhook(arg.ID)
+	return 1, nil
+}
+
+func (m *MemoryStore) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follows := map[uuid.UUID]FeedFollow{}
+	for _, follow := range m.feedFollows {
+		if follow.FeedID == feedID && follow.Notify == "all" {
+			follows[follow.UserID] = follow
+		}
+	}
+	var webhooks []Webhook
+	for _, webhook := range m.webhooks {
+		follow, ok := follows[webhook.UserID]
+		if !ok {
+			continue
+		}
+		if webhook.FeedID.Valid && webhook.FeedID.UUID != feedID {
+			continue
+		}
+		if webhook.CategoryID.Valid && webhook.CategoryID != follow.CategoryID {
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	sortWebhooks(webhooks)
+	return webhooks, nil
+}
+
+func sortWebhooks(webhooks []Webhook) {
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID.String() < webhooks[j].ID.String()
+	})
+}
+
+func (m *MemoryStore) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createWebhookDelivery(arg)
+}
+
+func (m *MemoryStore) CreateWebhookDeliveries(ctx context.Context, deliveries []CreateWebhookDeliveryParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check every ID first so that a conflict queues nothing, as a
+	// transaction would
+	for _, delivery := range deliveries {
+		if _, ok := m.deliveries[delivery.ID]; ok {
+			return ErrUniqueViolation
+		}
+	}
+	for _, delivery := range deliveries {
+		if err := m.createWebhookDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) createWebhookDelivery(arg CreateWebhookDeliveryParams) error {
+	webhook, ok := m.webhooks[arg.WebhookID]
+	if !ok {
+		return nil
+	}
+	if _, ok := m.posts[arg.PostID]; !ok || m.isHidden(webhook.UserID, arg.PostID) {
+		return nil
+	}
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == arg.WebhookID && delivery.PostID == arg.PostID {
+			return nil
+		}
+	}
+	if _, ok := m.deliveries[arg.ID]; ok {
+		return ErrUniqueViolation
+	}
+	m.deliveries[arg.ID] = WebhookDelivery{
+		ID:            arg.ID,
+		CreatedAt:     arg.CreatedAt,
+		UpdatedAt:     arg.CreatedAt,
+		WebhookID:     arg.WebhookID,
+		PostID:        arg.PostID,
+		Payload:       arg.Payload,
+		Status:        "pending",
+		NextAttemptAt: arg.CreatedAt,
+	}
+	return nil
+}
+
+func (m *MemoryStore) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == "pending" && !delivery.NextAttemptAt.After(arg.Now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > int(arg.MaxDeliveries) {
+		due = due[:arg.MaxDeliveries]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = arg.LeaseUntil
+		m.deliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (m *MemoryStore) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[arg.ID]
+	if !ok {
+		return nil
+	}
+	delivery.Status = arg.Status
+	delivery.Attempts++
+	delivery.NextAttemptAt = arg.NextAttemptAt
+	delivery.LastAttemptAt = arg.LastAttemptAt
+	delivery.ResponseStatus = arg.ResponseStatus
+	delivery.Error = arg.Error
+	delivery.UpdatedAt = time.Now()
+	m.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (m *MemoryStore) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deliveries []WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == arg.WebhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID.String() < deliveries[j].ID.String()
+	})
+	if len(deliveries) > int(arg.Limit) {
+		deliveries = deliveries[:arg.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryStore) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.TargetFeedID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	following := map[uuid.UUID]bool{}
+	for _, follow := range m.feedFollows {
+		if follow.FeedID == arg.TargetFeedID {
+			following[follow.UserID] = true
+		}
+	}
+	for id, follow := range m.feedFollows {
+		if follow.FeedID != arg.SourceFeedID || following[follow.UserID] {
+			continue
+		}
+		follow.FeedID = arg.TargetFeedID
+		follow.UpdatedAt = time.Now()
+		m.feedFollows[id] = follow
+	}
+	return nil
+}
+
+// postFromParams builds the row CreatePost and CreatePosts insert, with the
+// column defaults applied.
+func postFromParams(arg CreatePostParams) Post {
+	return Post{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Title:          arg.Title,
+		Url:            arg.Url,
+		Description:    arg.Description,
+		PublishedAt:    arg.PublishedAt,
+		FeedID:         arg.FeedID,
+		ContentHtml:    arg.ContentHtml,
+		Authors:        jsonArray(arg.Authors),
+		Categories:     jsonArray(arg.Categories),
+		Guid:           arg.Guid,
+		Enclosures:     jsonArray(arg.Enclosures),
+		DescriptionRaw: arg.DescriptionRaw,
+		ContentHtmlRaw: arg.ContentHtmlRaw,
+		ContentText:    arg.ContentText,
+		CanonicalUrl:   arg.CanonicalUrl,
+		TitleKey:       arg.TitleKey,
+		Simhash:        arg.Simhash,
+		ClusterID:      postClusterID(arg),
+	}
+}
+
+func (m *MemoryStore) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.posts[arg.ID]; ok {
+		return Post{}, ErrUniqueViolation
+	}
+	if _, ok := m.feeds[arg.FeedID]; !ok {
+		return Post{}, ErrForeignKeyViolation
+	}
+	for _, post := range m.posts {
+		if post.FeedID == arg.FeedID && (post.Url == arg.Url || post.CanonicalUrl == arg.CanonicalUrl) {
+			return Post{}, sql.ErrNoRows
+		}
+	}
+	post := postFromParams(arg)
+	m.posts[post.ID] = post
+	return post, nil
+}
+
+// postURL is a URL of a post, which is unique within its feed.
+type postURL struct {
+	FeedID uuid.UUID
+	URL    string
+}
+
+func (m *MemoryStore) CreatePosts(ctx context.Context, posts []CreatePostParams) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check every post before inserting any, so that the batch is all or
+	// nothing
+	urls := map[postURL]bool{}
+	canonicalURLs := map[postURL]bool{}
+	for _, post := range m.posts {
+		urls[postURL{post.FeedID, post.Url}] = true
+		canonicalURLs[postURL{post.FeedID, post.CanonicalUrl}] = true
+	}
+	for _, arg := range posts {
+		if _, ok := m.posts[arg.ID]; ok {
+			return nil, ErrUniqueViolation
+		}
+		if _, ok := m.feeds[arg.FeedID]; !ok {
+			return nil, ErrForeignKeyViolation
+		}
+	}
+
+	var created []uuid.UUID
+	for _, arg := range posts {
+		url, canonical := postURL{arg.FeedID, arg.Url}, postURL{arg.FeedID, arg.CanonicalUrl}
+		if urls[url] || canonicalURLs[canonical] {
+			continue
+		}
+		urls[url] = true
+		canonicalURLs[canonical] = true
+		m.posts[arg.ID] = postFromParams(arg)
+		created = append(created, arg.ID)
+	}
+	return created, nil
+}
+
+func (m *MemoryStore) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	follows := map[uuid.UUID]FeedFollow{}
+	for _, follow := range m.feedFollows {
+		if follow.UserID == arg.UserID {
+			follows[follow.FeedID] = follow
+		}
+	}
+	var posts []Post
+	for _, post := range m.posts {
+		follow, ok := follows[post.FeedID]
+		if !ok {
+			continue
+		}
+		if follow.Muted && !arg.FeedID.Valid {
+			continue
+		}
+		if m.isHidden(arg.UserID, post.ID) {
+			continue
+		}
+		if arg.CategoryID.Valid && follow.CategoryID != arg.CategoryID {
+			continue
+		}
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		if arg.Category.Valid && !hasCategory(post, arg.Category.String) {
+			continue
+		}
+		if arg.HasMedia {
+			var enclosures []json.RawMessage
+			json.Unmarshal(post.Enclosures, &enclosures)
+			if len(enclosures) == 0 {
+				continue
+			}
+		}
+		posts = append(posts, post)
+	}
+	if arg.Collapse {
+		posts = firstOfClusters(posts)
+	}
+	// Postgres sorts NULLs first in descending order
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i].PublishedAt, posts[j].PublishedAt
+		if !a.Valid || !b.Valid {
+			return !a.Valid && b.Valid
+		}
+		return a.Time.After(b.Time)
+	})
+	if len(posts) > int(arg.Limit) {
+		posts = posts[:arg.Limit]
+	}
+	return posts, nil
+}
+
+func hasCategory(post Post, category string) bool {
+	var categories []string
+	json.Unmarshal(post.Categories, &categories)
+	for _, name := range categories {
+		if strings.EqualFold(name, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstOfClusters keeps only the first stored post of each cluster.
+func firstOfClusters(posts []Post) []Post {
+	first := map[uuid.UUID]Post{}
+	for _, post := range posts {
+		current, ok := first[post.ClusterID]
+		if !ok || storedBefore(post, current) {
+			first[post.ClusterID] = post
+		}
+	}
+	var result []Post
+	for _, post := range posts {
+		if first[post.ClusterID].ID == post.ID {
+			result = append(result, post)
+		}
+	}
+	return result
+}
+
+func storedBefore(a, b Post) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+func (m *MemoryStore) GetClusterPostsForUser(ctx context.Context, arg GetClusterPostsForUserParams) ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followed := map[uuid.UUID]bool{}
+	for _, follow := range m.feedFollows {
+		if follow.UserID == arg.UserID && !follow.Muted {
+			followed[follow.FeedID] = true
+		}
+	}
+	var posts []Post
+	for _, post := range m.posts {
+		if followed[post.FeedID] && slices.Contains(arg.ClusterIds, post.ClusterID) && !m.isHidden(arg.UserID, post.ID) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return storedBefore(posts[i], posts[j])
+	})
+	return posts, nil
+}
+
+func (m *MemoryStore) GetPostFingerprintCandidates(ctx context.Context, since time.Time, posts []CreatePostParams) ([]GetPostFingerprintCandidatesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []GetPostFingerprintCandidatesRow
+	for _, post := range m.posts {
+		if post.CreatedAt.Before(since) || !slices.ContainsFunc(posts, func(arg CreatePostParams) bool {
+			return fingerprintCandidate(post, arg)
+		}) {
+			continue
+		}
+		rows = append(rows, GetPostFingerprintCandidatesRow{
+			ID:           post.ID,
+			FeedID:       post.FeedID,
+			ClusterID:    post.ClusterID,
+			CanonicalUrl: post.CanonicalUrl,
+			TitleKey:     post.TitleKey,
+			Simhash:      post.Simhash,
+		})
+	}
+	return rows, nil
+}
+
+// fingerprintCandidate reports whether a stored post shares a canonical URL, a
+// title key or a simhash band with a new one.
+func fingerprintCandidate(post Post, arg CreatePostParams) bool {
+	if post.CanonicalUrl == arg.CanonicalUrl {
+		return true
+	}
+	if post.TitleKey != "" && post.TitleKey == arg.TitleKey {
+		return true
+	}
+	if post.Simhash == 0 || arg.Simhash == 0 {
+		return false
+	}
+	bands, other := simhashBands(post.Simhash), simhashBands(arg.Simhash)
+	for i := range bands {
+		if bands[i] == other[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.TargetFeedID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	// Posts the target feed already has are left behind, to be deleted
+	// along with the source feed
+	urls := map[string]bool{}
+	canonicalURLs := map[string]bool{}
+	for _, post := range m.posts {
+		if post.FeedID == arg.TargetFeedID {
+			urls[post.Url] = true
+			canonicalURLs[post.CanonicalUrl] = true
+		}
+	}
+	for id, post := range m.posts {
+		if post.FeedID == arg.SourceFeedID && !urls[post.Url] && !canonicalURLs[post.CanonicalUrl] {
+			post.FeedID = arg.TargetFeedID
+			post.UpdatedAt = time.Now()
+			m.posts[id] = post
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UserFollowsPost(ctx context.Context, arg UserFollowsPostParams) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[arg.PostID]
+	if !ok {
+		return false, nil
+	}
+	for _, follow := range m.feedFollows {
+		if follow.UserID == arg.UserID && follow.FeedID == post.FeedID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) StarPost(ctx context.Context, arg StarPostParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if _, ok := m.posts[arg.PostID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	key := postStar{UserID: arg.UserID, PostID: arg.PostID}
+	if _, ok := m.postStars[key]; !ok {
+		m.postStars[key] = arg.CreatedAt
+	}
+	return nil
+}
+
+func (m *MemoryStore) UnstarPost(ctx context.Context, arg UnstarPostParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.postStars, postStar{UserID: arg.UserID, PostID: arg.PostID})
+	return nil
+}
+
+// postAge returns the time a post is aged from by the retention queries.
+func postAge(post Post) time.Time {
+	if post.PublishedAt.Valid {
+		return post.PublishedAt.Time
+	}
+	return post.CreatedAt
+}
+
+func (m *MemoryStore) DeleteOldPosts(ctx context.Context, arg DeleteOldPostsParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-time.Duration(arg.MaxAgeSeconds * float64(time.Second)))
+	var deleted int64
+	for id, post := range m.posts {
+		if deleted >= int64(arg.BatchSize) {
+			break
+		}
+		if postAge(post).Before(cutoff) && !m.isStarred(id) {
+			m.deletePost(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) DeleteExcessPosts(ctx context.Context, arg DeleteExcessPostsParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byFeed := map[uuid.UUID][]Post{}
+	for id, post := range m.posts {
+		if !m.isStarred(id) {
+			byFeed[post.FeedID] = append(byFeed[post.FeedID], post)
+		}
+	}
+
+	var deleted int64
+	for _, posts := range byFeed {
+		sort.Slice(posts, func(i, j int) bool {
+			return postAge(posts[i]).After(postAge(posts[j]))
+		})
+		for i := int(arg.MaxPosts); i < len(posts); i++ {
+			if deleted >= int64(arg.BatchSize) {
+				return deleted, nil
+			}
+			m.deletePost(posts[i].ID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -6,38 +6,99 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
-}
-
-type FeedFollow struct {
+type Category struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
-	FeedID    uuid.UUID
+	Name      string
 }
 
-type Post struct {
+type Feed struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	LastFetchedAt  sql.NullTime
+	LockedBy       sql.NullString
+	LockedUntil    sql.NullTime
+	RequestHeaders json.RawMessage
+	Status         string
+	NotFoundSince  sql.NullTime
+	CanonicalUrl   string
+}
+
+type FeedFollow struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
+	UserID      uuid.UUID
 	FeedID      uuid.UUID
+	CategoryID  uuid.NullUUID
+	CustomTitle sql.NullString
+	Muted       bool
+	Notify      string
+}
+
+type FeedUrlAlias struct {
+	Url       string
+	FeedID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type FilterRule struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	Field       string
+	PatternType string
+	Pattern     string
+	Action      string
+}
+
+type Post struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Title          string
+	Url            string
+	Description    sql.NullString
+	PublishedAt    sql.NullTime
+	FeedID         uuid.UUID
+	ContentHtml    sql.NullString
+	Authors        json.RawMessage
+	Categories     json.RawMessage
+	Guid           sql.NullString
+	Enclosures     json.RawMessage
+	DescriptionRaw sql.NullString
+	ContentHtmlRaw sql.NullString
+	ContentText    sql.NullString
+	CanonicalUrl   string
+	TitleKey       string
+	Simhash        int64
+	ClusterID      uuid.UUID
+}
+
+type PostRuleMatch struct {
+	RuleID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
@@ -46,4 +107,31 @@ type User struct {
 	UpdatedAt time.Time
 	Name      string
 	ApiKey    string
+	FeedToken string
+}
+
+type Webhook struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	FeedID     uuid.NullUUID
+	CategoryID uuid.NullUUID
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.UUID
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
 }
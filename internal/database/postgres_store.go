@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostgresStore implements Store with the sqlc generated Queries.
+type PostgresStore struct {
+	*Queries
+	db *sql.DB
+	// tx is set on the store handed to an InTx callback
+	tx *sql.Tx
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		Queries: New(db),
+		db:      db,
+	}
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Calling InTx on the store passed to fn reuses the same
+// transaction.
+func (s *PostgresStore) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&PostgresStore{
+		Queries: s.Queries.WithTx(tx),
+		db:      s.db,
+		tx:      tx,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// postJSON is the shape of a post in the batch given to InsertPostsJSON.
+type postJSON struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Title       string          `json:"title"`
+	Url         string          `json:"url"`
+	Description *string         `json:"description"`
+	PublishedAt *time.Time      `json:"published_at"`
+	FeedID      uuid.UUID       `json:"feed_id"`
+	ContentHtml *string         `json:"content_html"`
+	Authors     json.RawMessage `json:"authors"`
+	Categories  json.RawMessage `json:"categories"`
+	Guid        *string         `json:"guid"`
+	Enclosures  json.RawMessage `json:"enclosures"`
+
+	DescriptionRaw *string   `json:"description_raw"`
+	ContentHtmlRaw *string   `json:"content_html_raw"`
+	ContentText    *string   `json:"content_text"`
+	CanonicalUrl   string    `json:"canonical_url"`
+	TitleKey       string    `json:"title_key"`
+	Simhash        int64     `json:"simhash"`
+	ClusterID      uuid.UUID `json:"cluster_id"`
+}
+
+// jsonArray returns list, or an empty JSON array if it is unset, for the
+// columns that hold a NOT NULL JSON array.
+func jsonArray(list json.RawMessage) json.RawMessage {
+	if len(list) == 0 {
+		return json.RawMessage("[]")
+	}
+	return list
+}
+
+// postClusterID returns the cluster of a post: the post itself, unless it was
+// matched to an earlier one.
+func postClusterID(post CreatePostParams) uuid.UUID {
+	if post.ClusterID == uuid.Nil {
+		return post.ID
+	}
+	return post.ClusterID
+}
+
+// simhashBands splits a simhash into four 16-bit bands. Two simhashes that
+// differ by fewer than four bits have at least one band in common.
+func simhashBands(hash int64) [4]int64 {
+	var bands [4]int64
+	for i := range bands {
+		bands[i] = int64((uint64(hash) >> (16 * i)) & 0xffff)
+	}
+	return bands
+}
+
+func (s *PostgresStore) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	arg.Authors = jsonArray(arg.Authors)
+	arg.Categories = jsonArray(arg.Categories)
+	arg.Enclosures = jsonArray(arg.Enclosures)
+	arg.ClusterID = postClusterID(arg)
+	return s.Queries.CreatePost(ctx, arg)
+}
+
+func (s *PostgresStore) GetPostFingerprintCandidates(ctx context.Context, since time.Time, posts []CreatePostParams) ([]GetPostFingerprintCandidatesRow, error) {
+	arg := GetPostFingerprintCandidatesParams{Since: since}
+	bands := [4]*[]int64{&arg.SimhashBands0, &arg.SimhashBands1, &arg.SimhashBands2, &arg.SimhashBands3}
+	for _, post := range posts {
+		arg.CanonicalUrls = append(arg.CanonicalUrls, post.CanonicalUrl)
+		if post.TitleKey != "" {
+			arg.TitleKeys = append(arg.TitleKeys, post.TitleKey)
+		}
+		if post.Simhash != 0 {
+			for i, band := range simhashBands(post.Simhash) {
+				*bands[i] = append(*bands[i], band)
+			}
+		}
+	}
+	return s.Queries.GetPostFingerprintCandidates(ctx, arg)
+}
+
+// CreatePosts inserts the posts in a single statement and returns the IDs
+// of the new ones.
+func (s *PostgresStore) CreatePosts(ctx context.Context, posts []CreatePostParams) ([]uuid.UUID, error) {
+	if len(posts) == 0 {
+		return nil, nil
+	}
+
+	batch := make([]postJSON, len(posts))
+	for i, post := range posts {
+		batch[i] = postJSON{
+			ID:         post.ID,
+			CreatedAt:  post.CreatedAt,
+			UpdatedAt:  post.UpdatedAt,
+			Title:      post.Title,
+			Url:        post.Url,
+			FeedID:     post.FeedID,
+			Authors:    jsonArray(post.Authors),
+			Categories: jsonArray(post.Categories),
+			Enclosures: jsonArray(post.Enclosures),
+
+			CanonicalUrl: post.CanonicalUrl,
+			TitleKey:     post.TitleKey,
+			Simhash:      post.Simhash,
+			ClusterID:    postClusterID(post),
+		}
+		if post.Description.Valid {
+			batch[i].Description = &post.Description.String
+		}
+		if post.PublishedAt.Valid {
+			batch[i].PublishedAt = &post.PublishedAt.Time
+		}
+		if post.ContentHtml.Valid {
+			batch[i].ContentHtml = &post.ContentHtml.String
+		}
+		if post.Guid.Valid {
+			batch[i].Guid = &post.Guid.String
+		}
+		if post.DescriptionRaw.Valid {
+			batch[i].DescriptionRaw = &post.DescriptionRaw.String
+		}
+		if post.ContentHtmlRaw.Valid {
+			batch[i].ContentHtmlRaw = &post.ContentHtmlRaw.String
+		}
+		if post.ContentText.Valid {
+			batch[i].ContentText = &post.ContentText.String
+		}
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	return s.InsertPostsJSON(ctx, data)
+}
+
+// postRuleMatchJSON is the shape of a match in the batch given to
+// InsertPostRuleMatchesJSON.
+type postRuleMatchJSON struct {
+	RuleID    uuid.UUID `json:"rule_id"`
+	PostID    uuid.UUID `json:"post_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatePostRuleMatches records the matches in a single statement.
+func (s *PostgresStore) CreatePostRuleMatches(ctx context.Context, matches []CreatePostRuleMatchParams) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	batch := make([]postRuleMatchJSON, len(matches))
+	for i, match := range matches {
+		batch[i] = postRuleMatchJSON{
+			RuleID:    match.RuleID,
+			PostID:    match.PostID,
+			CreatedAt: match.CreatedAt,
+		}
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return s.InsertPostRuleMatchesJSON(ctx, data)
+}
+
+// webhookDeliveryJSON is the shape of a delivery in the batch given to
+// InsertWebhookDeliveriesJSON.
+type webhookDeliveryJSON struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	PostID    uuid.UUID `json:"post_id"`
+	Payload   string    `json:"payload"`
+}
+
+// CreateWebhookDeliveries queues the deliveries in a single statement.
+func (s *PostgresStore) CreateWebhookDeliveries(ctx context.Context, deliveries []CreateWebhookDeliveryParams) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	batch := make([]webhookDeliveryJSON, len(deliveries))
+	for i, delivery := range deliveries {
+		batch[i] = webhookDeliveryJSON{
+			ID:        delivery.ID,
+			CreatedAt: delivery.CreatedAt,
+			WebhookID: delivery.WebhookID,
+			PostID:    delivery.PostID,
+			Payload:   delivery.Payload,
+		}
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return s.InsertWebhookDeliveriesJSON(ctx, data)
+}
@@ -8,28 +8,46 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+ON CONFLICT DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Title          string
+	Url            string
+	Description    sql.NullString
+	PublishedAt    sql.NullTime
+	FeedID         uuid.UUID
+	ContentHtml    sql.NullString
+	Authors        json.RawMessage
+	Categories     json.RawMessage
+	Guid           sql.NullString
+	Enclosures     json.RawMessage
+	DescriptionRaw sql.NullString
+	ContentHtmlRaw sql.NullString
+	ContentText    sql.NullString
+	CanonicalUrl   string
+	TitleKey       string
+	Simhash        int64
+	ClusterID      uuid.UUID
 }
 
+// Posts whose URL or canonical URL is already known in their feed are
+// skipped, returning no rows, so that a duplicate doesn't abort the
+// surrounding transaction.
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.ID,
@@ -40,6 +58,18 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.ContentHtml,
+		arg.Authors,
+		arg.Categories,
+		arg.Guid,
+		arg.Enclosures,
+		arg.DescriptionRaw,
+		arg.ContentHtmlRaw,
+		arg.ContentText,
+		arg.CanonicalUrl,
+		arg.TitleKey,
+		arg.Simhash,
+		arg.ClusterID,
 	)
 	var i Post
 	err := row.Scan(
@@ -51,26 +81,279 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.ContentHtml,
+		&i.Authors,
+		&i.Categories,
+		&i.Guid,
+		&i.Enclosures,
+		&i.DescriptionRaw,
+		&i.ContentHtmlRaw,
+		&i.ContentText,
+		&i.CanonicalUrl,
+		&i.TitleKey,
+		&i.Simhash,
+		&i.ClusterID,
 	)
 	return i, err
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
+const deleteExcessPosts = `-- name: DeleteExcessPosts :execrows
+
+DELETE FROM posts
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, ROW_NUMBER() OVER (
+            PARTITION BY p.feed_id
+            ORDER BY COALESCE(p.published_at, p.created_at) DESC
+        ) AS position
+        FROM posts p
+        WHERE NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    ) ranked
+    WHERE ranked.position > $1::bigint
+    LIMIT $2
+)
+`
+
+type DeleteExcessPostsParams struct {
+	MaxPosts  int64
+	BatchSize int32
+}
+
+// Deletes up to batch_size posts beyond the max_posts most recent ones of
+// each feed. Starred posts are kept and don't count towards max_posts.
+func (q *Queries) DeleteExcessPosts(ctx context.Context, arg DeleteExcessPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExcessPosts, arg.MaxPosts, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
 
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id FROM posts
+const deleteOldPosts = `-- name: DeleteOldPosts :execrows
+
+DELETE FROM posts
+WHERE id IN (
+    SELECT p.id FROM posts p
+    WHERE COALESCE(p.published_at, p.created_at) < NOW() - make_interval(secs => $1::float8)
+    AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    LIMIT $2
+)
+`
+
+type DeleteOldPostsParams struct {
+	MaxAgeSeconds float64
+	BatchSize     int32
+}
+
+// Deletes up to batch_size posts published (or, lacking a date, stored) more
+// than max_age_seconds ago. Starred posts are kept.
+func (q *Queries) DeleteOldPosts(ctx context.Context, arg DeleteOldPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldPosts, arg.MaxAgeSeconds, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getClusterPostsForUser = `-- name: GetClusterPostsForUser :many
+
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content_html, posts.authors, posts.categories, posts.guid, posts.enclosures, posts.description_raw, posts.content_html_raw, posts.content_text, posts.canonical_url, posts.title_key, posts.simhash, posts.cluster_id FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 WHERE feed_follows.user_id = $1
+AND NOT feed_follows.muted
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+)
+AND posts.cluster_id = ANY($2::uuid[])
+ORDER BY posts.created_at, posts.id
+`
+
+type GetClusterPostsForUserParams struct {
+	UserID     uuid.UUID
+	ClusterIds []uuid.UUID
+}
+
+// Returns the posts of the given clusters from the feeds the user follows
+// and hasn't muted, except those a hide rule of the user matched.
+func (q *Queries) GetClusterPostsForUser(ctx context.Context, arg GetClusterPostsForUserParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getClusterPostsForUser, arg.UserID, pq.Array(arg.ClusterIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.ContentHtml,
+			&i.Authors,
+			&i.Categories,
+			&i.Guid,
+			&i.Enclosures,
+			&i.DescriptionRaw,
+			&i.ContentHtmlRaw,
+			&i.ContentText,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+			&i.ClusterID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostFingerprintCandidates = `-- name: GetPostFingerprintCandidates :many
+
+SELECT p.id, p.feed_id, p.cluster_id, p.canonical_url, p.title_key, p.simhash
+FROM posts p
+WHERE p.created_at >= $1
+AND (
+    p.canonical_url = ANY($2::text[])
+    OR (p.title_key <> '' AND p.title_key = ANY($3::text[]))
+    OR (p.simhash <> 0 AND (p.simhash & 65535) = ANY($4::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 16) & 65535) = ANY($5::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 32) & 65535) = ANY($6::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 48) & 65535) = ANY($7::bigint[]))
+)
+`
+
+type GetPostFingerprintCandidatesParams struct {
+	Since         time.Time
+	CanonicalUrls []string
+	TitleKeys     []string
+	SimhashBands0 []int64
+	SimhashBands1 []int64
+	SimhashBands2 []int64
+	SimhashBands3 []int64
+}
+
+type GetPostFingerprintCandidatesRow struct {
+	ID           uuid.UUID
+	FeedID       uuid.UUID
+	ClusterID    uuid.UUID
+	CanonicalUrl string
+	TitleKey     string
+	Simhash      int64
+}
+
+// Returns the posts stored since the given time that new posts may match:
+// those sharing a canonical URL or a title key with one of them, or one of
+// the four 16-bit bands of their simhash, which any simhash a few bits away
+// does. Each term of the condition has an index.
+func (q *Queries) GetPostFingerprintCandidates(ctx context.Context, arg GetPostFingerprintCandidatesParams) ([]GetPostFingerprintCandidatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostFingerprintCandidates,
+		arg.Since,
+		pq.Array(arg.CanonicalUrls),
+		pq.Array(arg.TitleKeys),
+		pq.Array(arg.SimhashBands0),
+		pq.Array(arg.SimhashBands1),
+		pq.Array(arg.SimhashBands2),
+		pq.Array(arg.SimhashBands3),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostFingerprintCandidatesRow
+	for rows.Next() {
+		var i GetPostFingerprintCandidatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FeedID,
+			&i.ClusterID,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+
+WITH visible AS (
+    SELECT posts.id, ROW_NUMBER() OVER (
+        PARTITION BY posts.cluster_id
+        ORDER BY posts.created_at, posts.id
+    ) AS position
+    FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = $3
+    AND (NOT $4::bool OR jsonb_array_length(posts.enclosures) > 0)
+    AND ($5::uuid IS NULL OR posts.feed_id = $5)
+    AND (NOT feed_follows.muted OR $5::uuid IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_rule_matches m
+        JOIN filter_rules r ON r.id = m.rule_id
+        WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+    )
+    AND ($6::uuid IS NULL OR feed_follows.category_id = $6)
+    AND ($7::text IS NULL OR EXISTS (
+        SELECT 1 FROM jsonb_array_elements_text(posts.categories) AS c(name)
+        WHERE lower(c.name) = lower($7)
+    ))
+)
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content_html, posts.authors, posts.categories, posts.guid, posts.enclosures, posts.description_raw, posts.content_html_raw, posts.content_text, posts.canonical_url, posts.title_key, posts.simhash, posts.cluster_id FROM posts
+JOIN visible ON visible.id = posts.id
+WHERE NOT $1::bool OR visible.position = 1
 ORDER BY posts.published_at DESC
 LIMIT $2
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	Collapse   bool
+	Limit      int32
+	UserID     uuid.UUID
+	HasMedia   bool
+	FeedID     uuid.NullUUID
+	CategoryID uuid.NullUUID
+	Category   sql.NullString
 }
 
+// Posts of muted follows are left out, unless they are asked for with
+// feed_id, as are those a hide rule of the user matched when they were
+// stored. feed_id, category_id and category, if set, narrow the posts down
+// to one feed, to the feeds the user filed into one of their categories or
+// to the posts tagged with a category. With collapse, only the first stored
+// post of each cluster among the remaining ones is returned.
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.Collapse,
+		arg.Limit,
+		arg.UserID,
+		arg.HasMedia,
+		arg.FeedID,
+		arg.CategoryID,
+		arg.Category,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +370,18 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.ContentHtml,
+			&i.Authors,
+			&i.Categories,
+			&i.Guid,
+			&i.Enclosures,
+			&i.DescriptionRaw,
+			&i.ContentHtmlRaw,
+			&i.ContentText,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+			&i.ClusterID,
 		); err != nil {
 			return nil, err
 		}
@@ -100,3 +395,136 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const insertPostsJSON = `-- name: InsertPostsJSON :many
+
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content_html, p.authors, p.categories, p.guid, p.enclosures, p.description_raw, p.content_html_raw, p.content_text, p.canonical_url, p.title_key, p.simhash, p.cluster_id
+FROM jsonb_to_recordset($1::jsonb) AS p(
+    id UUID,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    title TEXT,
+    url TEXT,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id UUID,
+    content_html TEXT,
+    authors JSONB,
+    categories JSONB,
+    guid TEXT,
+    enclosures JSONB,
+    description_raw TEXT,
+    content_html_raw TEXT,
+    content_text TEXT,
+    canonical_url TEXT,
+    title_key TEXT,
+    simhash BIGINT,
+    cluster_id UUID
+)
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+
+// Inserts a whole batch of posts, given as a JSON array of objects, in one
+// statement. Posts whose URL or canonical URL is already known in their feed
+// are skipped; the IDs of the new posts are returned.
+func (q *Queries) InsertPostsJSON(ctx context.Context, posts json.RawMessage) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, insertPostsJSON, posts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePostsToFeed = `-- name: MovePostsToFeed :exec
+
+UPDATE posts
+SET feed_id = $1,
+updated_at = NOW()
+WHERE posts.feed_id = $2
+AND posts.url NOT IN (SELECT t.url FROM posts t WHERE t.feed_id = $1)
+AND posts.canonical_url NOT IN (SELECT t.canonical_url FROM posts t WHERE t.feed_id = $1)
+`
+
+type MovePostsToFeedParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+// Posts the target feed already has are left behind, to be deleted along
+// with the source feed.
+func (q *Queries) MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error {
+	_, err := q.db.ExecContext(ctx, movePostsToFeed, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const starPost = `-- name: StarPost :exec
+
+INSERT INTO post_stars (user_id, post_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type StarPostParams struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost, arg.UserID, arg.PostID, arg.CreatedAt)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :exec
+
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2
+`
+
+type UnstarPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) error {
+	_, err := q.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	return err
+}
+
+const userFollowsPost = `-- name: UserFollowsPost :one
+
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE posts.id = $1 AND feed_follows.user_id = $2
+)
+`
+
+type UserFollowsPostParams struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
+}
+
+// Reports whether the post belongs to a feed the user follows.
+func (q *Queries) UserFollowsPost(ctx context.Context, arg UserFollowsPostParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userFollowsPost, arg.PostID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
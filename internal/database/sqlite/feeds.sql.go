@@ -0,0 +1,655 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: feeds.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFeed = `-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, request_headers, canonical_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type CreateFeedParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	RequestHeaders string
+	CanonicalUrl   string
+}
+
+func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, createFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.RequestHeaders,
+		arg.CanonicalUrl,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const createFeedFollow = `-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
+`
+
+type CreateFeedFollowParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+}
+
+func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, createFeedFollow,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const createFeedURLAlias = `-- name: CreateFeedURLAlias :exec
+INSERT INTO feed_url_aliases (url, feed_id, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (url) DO UPDATE SET feed_id = excluded.feed_id
+`
+
+type CreateFeedURLAliasParams struct {
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) CreateFeedURLAlias(ctx context.Context, arg CreateFeedURLAliasParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedURLAlias, arg.Url, arg.FeedID)
+	return err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = ?
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows WHERE id = ? AND user_id = ?
+`
+
+type DeleteFeedFollowParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedFollow, arg.ID, arg.UserID)
+	return err
+}
+
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.locked_by, feeds.locked_until, feeds.request_headers, feeds.status, feeds.not_found_since, feeds.canonical_url FROM feeds
+WHERE feeds.url = ?1
+OR feeds.canonical_url = ?2
+OR feeds.id = (SELECT aliases.feed_id FROM feed_url_aliases aliases WHERE aliases.url = ?1)
+LIMIT 1
+`
+
+type GetFeedByURLParams struct {
+	Url          string
+	CanonicalUrl string
+}
+
+func (q *Queries) GetFeedByURL(ctx context.Context, arg GetFeedByURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, arg.Url, arg.CanonicalUrl)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
+SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.category_id, feed_follows.custom_title, feed_follows.muted, feed_follows.notify, feeds.name AS feed_name, feeds.status AS feed_status, categories.name AS category_name
+FROM feed_follows
+JOIN feeds ON feeds.id = feed_follows.feed_id
+LEFT JOIN categories ON categories.id = feed_follows.category_id
+WHERE feed_follows.user_id = ?
+`
+
+type GetFeedFollowsForUserRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	FeedID       uuid.UUID
+	CategoryID   uuid.NullUUID
+	CustomTitle  sql.NullString
+	Muted        bool
+	Notify       string
+	FeedName     string
+	FeedStatus   string
+	CategoryName sql.NullString
+}
+
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFollowsForUserRow
+	for rows.Next() {
+		var i GetFeedFollowsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.CategoryID,
+			&i.CustomTitle,
+			&i.Muted,
+			&i.Notify,
+			&i.FeedName,
+			&i.FeedStatus,
+			&i.CategoryName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeeds = `-- name: GetFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url FROM feeds
+`
+
+func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.LockedBy,
+			&i.LockedUntil,
+			&i.RequestHeaders,
+			&i.Status,
+			&i.NotFoundSince,
+			&i.CanonicalUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
+UPDATE feeds
+SET locked_by = ?1,
+locked_until = datetime('now', '+' || CAST(?2 AS INTEGER) || ' seconds')
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE status NOT IN ('paused', 'gone')
+    AND (locked_until IS NULL OR locked_until < datetime('now'))
+    ORDER BY last_fetched_at IS NOT NULL, last_fetched_at ASC
+    LIMIT ?3
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type GetNextFeedsToFetchParams struct {
+	LockedBy     sql.NullString
+	LeaseSeconds int64
+	MaxFeeds     int64
+}
+
+// SQLite serializes writers, so claiming feeds with a single UPDATE is atomic
+// without row locks.
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.LockedBy, arg.LeaseSeconds, arg.MaxFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.LockedBy,
+			&i.LockedUntil,
+			&i.RequestHeaders,
+			&i.Status,
+			&i.NotFoundSince,
+			&i.CanonicalUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markFeedFetched = `-- name: MarkFeedFetched :one
+UPDATE feeds
+SET last_fetched_at = CURRENT_TIMESTAMP,
+updated_at = CURRENT_TIMESTAMP,
+locked_by = NULL,
+locked_until = NULL
+WHERE id = ?1 AND locked_by = ?2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type MarkFeedFetchedParams struct {
+	ID       uuid.UUID
+	LockedBy sql.NullString
+}
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFetched, arg.ID, arg.LockedBy)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const markFeedGone = `-- name: MarkFeedGone :exec
+UPDATE feeds
+SET status = 'gone',
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+AND status <> 'paused'
+`
+
+// A feed paused while it was being fetched stays paused.
+func (q *Queries) MarkFeedGone(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedGone, id)
+	return err
+}
+
+const markFeedHealthy = `-- name: MarkFeedHealthy :exec
+UPDATE feeds
+SET status = 'active',
+not_found_since = NULL,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+AND (status = 'broken' OR not_found_since IS NOT NULL)
+AND status <> 'paused'
+`
+
+// A feed paused while it was being fetched stays paused.
+func (q *Queries) MarkFeedHealthy(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedHealthy, id)
+	return err
+}
+
+const markFeedNotFound = `-- name: MarkFeedNotFound :one
+UPDATE feeds
+SET not_found_since = COALESCE(not_found_since, CURRENT_TIMESTAMP),
+status = CASE
+    WHEN not_found_since < datetime('now', '-' || CAST(?1 AS INTEGER) || ' seconds') THEN 'broken'
+    ELSE status
+END,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type MarkFeedNotFoundParams struct {
+	BrokenAfterSeconds int64
+	ID                 uuid.UUID
+}
+
+// Returns no rows if the feed was paused while it was being fetched.
+func (q *Queries) MarkFeedNotFound(ctx context.Context, arg MarkFeedNotFoundParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedNotFound, arg.BrokenAfterSeconds, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = ?1,
+updated_at = CURRENT_TIMESTAMP
+WHERE feed_follows.feed_id = ?2
+AND feed_follows.user_id NOT IN (
+    SELECT target.user_id FROM feed_follows target WHERE target.feed_id = ?1
+)
+`
+
+type MoveFeedFollowsParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const moveFeedURLAliases = `-- name: MoveFeedURLAliases :exec
+UPDATE feed_url_aliases
+SET feed_id = ?1
+WHERE feed_id = ?2
+`
+
+type MoveFeedURLAliasesParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedURLAliases(ctx context.Context, arg MoveFeedURLAliasesParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedURLAliases, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const setFeedFollowCategory = `-- name: SetFeedFollowCategory :one
+UPDATE feed_follows
+SET category_id = ?1,
+updated_at = CURRENT_TIMESTAMP
+WHERE feed_follows.id = ?2 AND feed_follows.user_id = ?3
+AND (?1 IS NULL OR EXISTS (
+    SELECT 1 FROM categories c
+    WHERE c.id = ?1 AND c.user_id = ?3
+))
+RETURNING id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
+`
+
+type SetFeedFollowCategoryParams struct {
+	CategoryID uuid.NullUUID
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowCategory, arg.CategoryID, arg.ID, arg.UserID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const setFeedStatus = `-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = ?,
+not_found_since = NULL,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ?
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type SetFeedStatusParams struct {
+	Status string
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedStatus, arg.Status, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const updateFeedFollow = `-- name: UpdateFeedFollow :one
+UPDATE feed_follows
+SET custom_title = NULLIF(COALESCE(CAST(?1 AS TEXT), custom_title), ''),
+muted = COALESCE(CAST(?2 AS BOOLEAN), muted),
+notify = COALESCE(CAST(?3 AS TEXT), notify),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?4 AND user_id = ?5
+RETURNING id, created_at, updated_at, user_id, feed_id, category_id, custom_title, muted, notify
+`
+
+type UpdateFeedFollowParams struct {
+	CustomTitle sql.NullString
+	Muted       sql.NullBool
+	Notify      sql.NullString
+	ID          uuid.UUID
+	UserID      uuid.UUID
+}
+
+func (q *Queries) UpdateFeedFollow(ctx context.Context, arg UpdateFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedFollow,
+		arg.CustomTitle,
+		arg.Muted,
+		arg.Notify,
+		arg.ID,
+		arg.UserID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CategoryID,
+		&i.CustomTitle,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const updateFeedRequestHeaders = `-- name: UpdateFeedRequestHeaders :one
+UPDATE feeds
+SET request_headers = ?,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ?
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type UpdateFeedRequestHeadersParams struct {
+	RequestHeaders string
+	ID             uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) UpdateFeedRequestHeaders(ctx context.Context, arg UpdateFeedRequestHeadersParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedRequestHeaders, arg.RequestHeaders, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = ?1,
+canonical_url = ?2,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?3
+AND status <> 'paused'
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, locked_by, locked_until, request_headers, status, not_found_since, canonical_url
+`
+
+type UpdateFeedURLParams struct {
+	Url          string
+	CanonicalUrl string
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedURL, arg.Url, arg.CanonicalUrl, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LockedBy,
+		&i.LockedUntil,
+		&i.RequestHeaders,
+		&i.Status,
+		&i.NotFoundSince,
+		&i.CanonicalUrl,
+	)
+	return i, err
+}
@@ -0,0 +1,233 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: filter_rules.sql
+
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFilterRule = `-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, action)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, "action"
+`
+
+type CreateFilterRuleParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	Field       string
+	PatternType string
+	Pattern     string
+	Action      string
+}
+
+func (q *Queries) CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, createFilterRule,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+		arg.Field,
+		arg.PatternType,
+		arg.Pattern,
+		arg.Action,
+	)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Field,
+		&i.PatternType,
+		&i.Pattern,
+		&i.Action,
+	)
+	return i, err
+}
+
+const createPostRuleMatch = `-- name: CreatePostRuleMatch :exec
+INSERT INTO post_rule_matches (rule_id, post_id, created_at)
+SELECT filter_rules.id, posts.id, ?1
+FROM filter_rules, posts
+WHERE filter_rules.id = ?2 AND posts.id = ?3
+ON CONFLICT (rule_id, post_id) DO NOTHING
+`
+
+type CreatePostRuleMatchParams struct {
+	CreatedAt time.Time
+	RuleID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+// The WHERE clause keeps SQLite from reading ON CONFLICT as part of the
+// SELECT.
+func (q *Queries) CreatePostRuleMatch(ctx context.Context, arg CreatePostRuleMatchParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRuleMatch, arg.CreatedAt, arg.RuleID, arg.PostID)
+	return err
+}
+
+const deleteFilterRule = `-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules WHERE id = ? AND user_id = ?
+`
+
+type DeleteFilterRuleParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteFilterRule(ctx context.Context, arg DeleteFilterRuleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFilterRule, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deletePostRuleMatches = `-- name: DeletePostRuleMatches :exec
+DELETE FROM post_rule_matches WHERE rule_id = ?
+`
+
+func (q *Queries) DeletePostRuleMatches(ctx context.Context, ruleID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePostRuleMatches, ruleID)
+	return err
+}
+
+const getFilterRulesForUser = `-- name: GetFilterRulesForUser :many
+SELECT id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, "action" FROM filter_rules
+WHERE user_id = ?
+ORDER BY julianday(created_at), id
+`
+
+func (q *Queries) GetFilterRulesForUser(ctx context.Context, userID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, getFilterRulesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.Field,
+			&i.PatternType,
+			&i.Pattern,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getHideRulesForFeed = `-- name: GetHideRulesForFeed :many
+SELECT filter_rules.id, filter_rules.created_at, filter_rules.updated_at, filter_rules.user_id, filter_rules.feed_id, filter_rules.field, filter_rules.pattern_type, filter_rules.pattern, filter_rules."action" FROM filter_rules
+JOIN feed_follows ON feed_follows.user_id = filter_rules.user_id
+WHERE feed_follows.feed_id = ?1
+AND filter_rules.action = 'hide'
+AND (filter_rules.feed_id IS NULL OR filter_rules.feed_id = ?1)
+ORDER BY julianday(filter_rules.created_at), filter_rules.id
+`
+
+func (q *Queries) GetHideRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, getHideRulesForFeed, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.Field,
+			&i.PatternType,
+			&i.Pattern,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateFilterRule = `-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET feed_id = ?1,
+field = ?2,
+pattern_type = ?3,
+pattern = ?4,
+action = ?5,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?6 AND user_id = ?7
+RETURNING id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, "action"
+`
+
+type UpdateFilterRuleParams struct {
+	FeedID      uuid.NullUUID
+	Field       string
+	PatternType string
+	Pattern     string
+	Action      string
+	ID          uuid.UUID
+	UserID      uuid.UUID
+}
+
+func (q *Queries) UpdateFilterRule(ctx context.Context, arg UpdateFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, updateFilterRule,
+		arg.FeedID,
+		arg.Field,
+		arg.PatternType,
+		arg.Pattern,
+		arg.Action,
+		arg.ID,
+		arg.UserID,
+	)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Field,
+		&i.PatternType,
+		&i.Pattern,
+		&i.Action,
+	)
+	return i, err
+}
@@ -0,0 +1,136 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Category struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type Feed struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	LastFetchedAt  sql.NullTime
+	LockedBy       sql.NullString
+	LockedUntil    sql.NullTime
+	RequestHeaders string
+	Status         string
+	NotFoundSince  sql.NullTime
+	CanonicalUrl   string
+}
+
+type FeedFollow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	CategoryID  uuid.NullUUID
+	CustomTitle sql.NullString
+	Muted       bool
+	Notify      string
+}
+
+type FeedUrlAlias struct {
+	Url       string
+	FeedID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type FilterRule struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	Field       string
+	PatternType string
+	Pattern     string
+	Action      string
+}
+
+type Post struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Title          string
+	Url            string
+	Description    sql.NullString
+	PublishedAt    sql.NullTime
+	FeedID         uuid.UUID
+	ContentHtml    sql.NullString
+	Authors        string
+	Categories     string
+	Guid           sql.NullString
+	Enclosures     string
+	DescriptionRaw sql.NullString
+	ContentHtmlRaw sql.NullString
+	ContentText    sql.NullString
+	CanonicalUrl   string
+	TitleKey       string
+	Simhash        int64
+	ClusterID      uuid.UUID
+}
+
+type PostRuleMatch struct {
+	RuleID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type User struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	ApiKey    string
+	FeedToken string
+}
+
+type Webhook struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	FeedID     uuid.NullUUID
+	CategoryID uuid.NullUUID
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.UUID
+	Payload        string
+	Status         string
+	Attempts       int64
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt64
+	Error          sql.NullString
+}
@@ -0,0 +1,463 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: posts.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createPost = `-- name: CreatePost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id
+`
+
+type CreatePostParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Title          string
+	Url            string
+	Description    sql.NullString
+	PublishedAt    sql.NullTime
+	FeedID         uuid.UUID
+	ContentHtml    sql.NullString
+	Authors        string
+	Categories     string
+	Guid           sql.NullString
+	Enclosures     string
+	DescriptionRaw sql.NullString
+	ContentHtmlRaw sql.NullString
+	ContentText    sql.NullString
+	CanonicalUrl   string
+	TitleKey       string
+	Simhash        int64
+	ClusterID      uuid.UUID
+}
+
+// Posts whose URL or canonical URL is already known in their feed are
+// skipped, returning no rows, so that a duplicate doesn't abort the
+// surrounding transaction.
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, createPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.ContentHtml,
+		arg.Authors,
+		arg.Categories,
+		arg.Guid,
+		arg.Enclosures,
+		arg.DescriptionRaw,
+		arg.ContentHtmlRaw,
+		arg.ContentText,
+		arg.CanonicalUrl,
+		arg.TitleKey,
+		arg.Simhash,
+		arg.ClusterID,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.ContentHtml,
+		&i.Authors,
+		&i.Categories,
+		&i.Guid,
+		&i.Enclosures,
+		&i.DescriptionRaw,
+		&i.ContentHtmlRaw,
+		&i.ContentText,
+		&i.CanonicalUrl,
+		&i.TitleKey,
+		&i.Simhash,
+		&i.ClusterID,
+	)
+	return i, err
+}
+
+const deleteExcessPosts = `-- name: DeleteExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, ROW_NUMBER() OVER (
+            PARTITION BY p.feed_id
+            ORDER BY datetime(COALESCE(p.published_at, p.created_at)) DESC
+        ) AS position
+        FROM posts p
+        WHERE NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    ) ranked
+    WHERE ranked.position > CAST(?1 AS INTEGER)
+    LIMIT ?2
+)
+`
+
+type DeleteExcessPostsParams struct {
+	MaxPosts  int64
+	BatchSize int64
+}
+
+func (q *Queries) DeleteExcessPosts(ctx context.Context, arg DeleteExcessPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExcessPosts, arg.MaxPosts, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOldPosts = `-- name: DeleteOldPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT p.id FROM posts p
+    WHERE datetime(COALESCE(p.published_at, p.created_at)) < datetime('now', '-' || CAST(?1 AS INTEGER) || ' seconds')
+    AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    LIMIT ?2
+)
+`
+
+type DeleteOldPostsParams struct {
+	MaxAgeSeconds int64
+	BatchSize     int64
+}
+
+// Timestamps are compared through datetime() since they are stored as text
+// with the offset they were parsed with.
+func (q *Queries) DeleteOldPosts(ctx context.Context, arg DeleteOldPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldPosts, arg.MaxAgeSeconds, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getClusterPostsForUser = `-- name: GetClusterPostsForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content_html, posts.authors, posts.categories, posts.guid, posts.enclosures, posts.description_raw, posts.content_html_raw, posts.content_text, posts.canonical_url, posts.title_key, posts.simhash, posts.cluster_id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?1
+AND NOT feed_follows.muted
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+)
+AND posts.cluster_id IN (/*SLICE:cluster_ids*/?)
+ORDER BY julianday(posts.created_at), posts.id
+`
+
+type GetClusterPostsForUserParams struct {
+	UserID     uuid.UUID
+	ClusterIds []uuid.UUID
+}
+
+func (q *Queries) GetClusterPostsForUser(ctx context.Context, arg GetClusterPostsForUserParams) ([]Post, error) {
+	query := getClusterPostsForUser
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.ClusterIds) > 0 {
+		for _, v := range arg.ClusterIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:cluster_ids*/?", strings.Repeat(",?", len(arg.ClusterIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:cluster_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.ContentHtml,
+			&i.Authors,
+			&i.Categories,
+			&i.Guid,
+			&i.Enclosures,
+			&i.DescriptionRaw,
+			&i.ContentHtmlRaw,
+			&i.ContentText,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+			&i.ClusterID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostFingerprintCandidates = `-- name: GetPostFingerprintCandidates :many
+SELECT p.id, p.feed_id, p.cluster_id, p.canonical_url, p.title_key, p.simhash
+FROM posts p
+WHERE datetime(p.created_at) >= datetime(?1)
+AND (
+    p.canonical_url = ?2
+    OR (p.title_key <> '' AND p.title_key = ?3)
+    OR (p.simhash <> 0 AND (p.simhash & 65535) = ?4)
+    OR (p.simhash <> 0 AND ((p.simhash >> 16) & 65535) = ?5)
+    OR (p.simhash <> 0 AND ((p.simhash >> 32) & 65535) = ?6)
+    OR (p.simhash <> 0 AND ((p.simhash >> 48) & 65535) = ?7)
+)
+`
+
+type GetPostFingerprintCandidatesParams struct {
+	Since        interface{}
+	CanonicalUrl string
+	TitleKey     string
+	SimhashBand0 int64
+	SimhashBand1 int64
+	SimhashBand2 int64
+	SimhashBand3 int64
+}
+
+type GetPostFingerprintCandidatesRow struct {
+	ID           uuid.UUID
+	FeedID       uuid.UUID
+	ClusterID    uuid.UUID
+	CanonicalUrl string
+	TitleKey     string
+	Simhash      int64
+}
+
+// Takes the fingerprint of a single post, the bands being -1 for posts
+// without a simhash.
+func (q *Queries) GetPostFingerprintCandidates(ctx context.Context, arg GetPostFingerprintCandidatesParams) ([]GetPostFingerprintCandidatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostFingerprintCandidates,
+		arg.Since,
+		arg.CanonicalUrl,
+		arg.TitleKey,
+		arg.SimhashBand0,
+		arg.SimhashBand1,
+		arg.SimhashBand2,
+		arg.SimhashBand3,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostFingerprintCandidatesRow
+	for rows.Next() {
+		var i GetPostFingerprintCandidatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FeedID,
+			&i.ClusterID,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+WITH visible AS (
+    SELECT posts.id, ROW_NUMBER() OVER (
+        PARTITION BY posts.cluster_id
+        ORDER BY julianday(posts.created_at), posts.id
+    ) AS position
+    FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = ?3
+    AND (CAST(?4 AS BOOLEAN) = FALSE OR json_array_length(posts.enclosures) > 0)
+    AND (?5 IS NULL OR posts.feed_id = ?5)
+    AND (NOT feed_follows.muted OR ?5 IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_rule_matches m
+        JOIN filter_rules r ON r.id = m.rule_id
+        WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+    )
+    AND (?6 IS NULL OR feed_follows.category_id = ?6)
+    AND (?7 IS NULL OR EXISTS (
+        SELECT 1 FROM json_each(posts.categories) AS c
+        WHERE lower(c.value) = lower(?7)
+    ))
+)
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content_html, posts.authors, posts.categories, posts.guid, posts.enclosures, posts.description_raw, posts.content_html_raw, posts.content_text, posts.canonical_url, posts.title_key, posts.simhash, posts.cluster_id FROM posts
+JOIN visible ON visible.id = posts.id
+WHERE CAST(?1 AS BOOLEAN) = FALSE OR visible.position = 1
+ORDER BY posts.published_at IS NOT NULL, julianday(posts.published_at) DESC
+LIMIT ?2
+`
+
+type GetPostsForUserParams struct {
+	Collapse   bool
+	Limit      int64
+	UserID     uuid.UUID
+	HasMedia   bool
+	FeedID     interface{}
+	CategoryID interface{}
+	Category   interface{}
+}
+
+// Times are compared with julianday(), which unlike datetime() keeps
+// fractions of a second and unlike the stored text accounts for their UTC
+// offset.
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.Collapse,
+		arg.Limit,
+		arg.UserID,
+		arg.HasMedia,
+		arg.FeedID,
+		arg.CategoryID,
+		arg.Category,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.ContentHtml,
+			&i.Authors,
+			&i.Categories,
+			&i.Guid,
+			&i.Enclosures,
+			&i.DescriptionRaw,
+			&i.ContentHtmlRaw,
+			&i.ContentText,
+			&i.CanonicalUrl,
+			&i.TitleKey,
+			&i.Simhash,
+			&i.ClusterID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePostsToFeed = `-- name: MovePostsToFeed :exec
+UPDATE posts
+SET feed_id = ?1,
+updated_at = CURRENT_TIMESTAMP
+WHERE posts.feed_id = ?2
+AND posts.url NOT IN (SELECT t.url FROM posts t WHERE t.feed_id = ?1)
+AND posts.canonical_url NOT IN (SELECT t.canonical_url FROM posts t WHERE t.feed_id = ?1)
+`
+
+type MovePostsToFeedParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error {
+	_, err := q.db.ExecContext(ctx, movePostsToFeed, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const starPost = `-- name: StarPost :exec
+INSERT INTO post_stars (user_id, post_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type StarPostParams struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost, arg.UserID, arg.PostID, arg.CreatedAt)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :exec
+DELETE FROM post_stars WHERE user_id = ? AND post_id = ?
+`
+
+type UnstarPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) error {
+	_, err := q.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	return err
+}
+
+const userFollowsPost = `-- name: UserFollowsPost :one
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE posts.id = ?1 AND feed_follows.user_id = ?2
+)
+`
+
+type UserFollowsPostParams struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UserFollowsPost(ctx context.Context, arg UserFollowsPostParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, userFollowsPost, arg.PostID, arg.UserID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
@@ -0,0 +1,587 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database/sqlite"
+	"github.com/google/uuid"
+)
+
+// SQLiteStore implements Store on top of the queries generated from
+// sql/sqlite/queries, for single-user and local deployments.
+type SQLiteStore struct {
+	q  *sqlite.Queries
+	db *sql.DB
+	// tx is set on the store handed to an InTx callback
+	tx *sql.Tx
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{
+		q:  sqlite.New(db),
+		db: db,
+	}
+}
+
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Calling InTx on the store passed to fn reuses the same
+// transaction.
+func (s *SQLiteStore) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(&SQLiteStore{
+		q:  s.q.WithTx(tx),
+		db: s.db,
+		tx: tx,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func feedFromSQLite(feed sqlite.Feed) Feed {
+	return Feed{
+		ID:             feed.ID,
+		CreatedAt:      feed.CreatedAt,
+		UpdatedAt:      feed.UpdatedAt,
+		Name:           feed.Name,
+		Url:            feed.Url,
+		UserID:         feed.UserID,
+		LastFetchedAt:  feed.LastFetchedAt,
+		LockedBy:       feed.LockedBy,
+		LockedUntil:    feed.LockedUntil,
+		RequestHeaders: json.RawMessage(feed.RequestHeaders),
+		Status:         feed.Status,
+		NotFoundSince:  feed.NotFoundSince,
+		CanonicalUrl:   feed.CanonicalUrl,
+	}
+}
+
+func feedsFromSQLite(feeds []sqlite.Feed) []Feed {
+	var result []Feed
+	for _, feed := range feeds {
+		result = append(result, feedFromSQLite(feed))
+	}
+	return result
+}
+
+func requestHeadersToSQLite(requestHeaders json.RawMessage) string {
+	if requestHeaders == nil {
+		return "{}"
+	}
+	return string(requestHeaders)
+}
+
+func postFromSQLite(post sqlite.Post) Post {
+	return Post{
+		ID:             post.ID,
+		CreatedAt:      post.CreatedAt,
+		UpdatedAt:      post.UpdatedAt,
+		Title:          post.Title,
+		Url:            post.Url,
+		Description:    post.Description,
+		PublishedAt:    post.PublishedAt,
+		FeedID:         post.FeedID,
+		ContentHtml:    post.ContentHtml,
+		Authors:        json.RawMessage(post.Authors),
+		Categories:     json.RawMessage(post.Categories),
+		Guid:           post.Guid,
+		Enclosures:     json.RawMessage(post.Enclosures),
+		DescriptionRaw: post.DescriptionRaw,
+		ContentHtmlRaw: post.ContentHtmlRaw,
+		ContentText:    post.ContentText,
+		CanonicalUrl:   post.CanonicalUrl,
+		TitleKey:       post.TitleKey,
+		Simhash:        post.Simhash,
+		ClusterID:      post.ClusterID,
+	}
+}
+
+func (s *SQLiteStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	// The api key is generated in Go since SQLite has no sha256 function
+	apiKey, err := NewToken()
+	if err != nil {
+		return User{}, err
+	}
+	user, err := s.q.CreateUser(ctx, sqlite.CreateUserParams{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		ApiKey:    apiKey,
+		FeedToken: arg.FeedToken,
+	})
+	return User(user), err
+}
+
+func (s *SQLiteStore) GetUserByApiKey(ctx context.Context, apiKey string) (User, error) {
+	user, err := s.q.GetUserByApiKey(ctx, apiKey)
+	return User(user), err
+}
+
+func (s *SQLiteStore) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := s.q.GetUserByID(ctx, id)
+	return User(user), err
+}
+
+func (s *SQLiteStore) RotateFeedToken(ctx context.Context, arg RotateFeedTokenParams) (User, error) {
+	user, err := s.q.RotateFeedToken(ctx, sqlite.RotateFeedTokenParams(arg))
+	return User(user), err
+}
+
+func (s *SQLiteStore) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	feed, err := s.q.CreateFeed(ctx, sqlite.CreateFeedParams{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Name:           arg.Name,
+		Url:            arg.Url,
+		UserID:         arg.UserID,
+		RequestHeaders: requestHeadersToSQLite(arg.RequestHeaders),
+		CanonicalUrl:   arg.CanonicalUrl,
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteFeed(ctx, id)
+}
+
+func (s *SQLiteStore) GetFeedByURL(ctx context.Context, arg GetFeedByURLParams) (Feed, error) {
+	feed, err := s.q.GetFeedByURL(ctx, sqlite.GetFeedByURLParams(arg))
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) GetFeeds(ctx context.Context) ([]Feed, error) {
+	feeds, err := s.q.GetFeeds(ctx)
+	return feedsFromSQLite(feeds), err
+}
+
+func (s *SQLiteStore) CreateFeedURLAlias(ctx context.Context, arg CreateFeedURLAliasParams) error {
+	return s.q.CreateFeedURLAlias(ctx, sqlite.CreateFeedURLAliasParams(arg))
+}
+
+func (s *SQLiteStore) MoveFeedURLAliases(ctx context.Context, arg MoveFeedURLAliasesParams) error {
+	return s.q.MoveFeedURLAliases(ctx, sqlite.MoveFeedURLAliasesParams(arg))
+}
+
+func (s *SQLiteStore) UpdateFeedRequestHeaders(ctx context.Context, arg UpdateFeedRequestHeadersParams) (Feed, error) {
+	feed, err := s.q.UpdateFeedRequestHeaders(ctx, sqlite.UpdateFeedRequestHeadersParams{
+		RequestHeaders: requestHeadersToSQLite(arg.RequestHeaders),
+		ID:             arg.ID,
+		UserID:         arg.UserID,
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	feed, err := s.q.UpdateFeedURL(ctx, sqlite.UpdateFeedURLParams{
+		Url:          arg.Url,
+		CanonicalUrl: arg.CanonicalUrl,
+		ID:           arg.ID,
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error) {
+	feed, err := s.q.SetFeedStatus(ctx, sqlite.SetFeedStatusParams{
+		Status: arg.Status,
+		ID:     arg.ID,
+		UserID: arg.UserID,
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	feeds, err := s.q.GetNextFeedsToFetch(ctx, sqlite.GetNextFeedsToFetchParams{
+		LockedBy:     sql.NullString{String: arg.LockedBy, Valid: true},
+		LeaseSeconds: int64(arg.LeaseSeconds),
+		MaxFeeds:     int64(arg.MaxFeeds),
+	})
+	return feedsFromSQLite(feeds), err
+}
+
+func (s *SQLiteStore) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	feed, err := s.q.MarkFeedFetched(ctx, sqlite.MarkFeedFetchedParams{
+		ID:       arg.ID,
+		LockedBy: sql.NullString{String: arg.LockedBy, Valid: true},
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) MarkFeedGone(ctx context.Context, id uuid.UUID) error {
+	return s.q.MarkFeedGone(ctx, id)
+}
+
+func (s *SQLiteStore) MarkFeedHealthy(ctx context.Context, id uuid.UUID) error {
+	return s.q.MarkFeedHealthy(ctx, id)
+}
+
+func (s *SQLiteStore) MarkFeedNotFound(ctx context.Context, arg MarkFeedNotFoundParams) (Feed, error) {
+	feed, err := s.q.MarkFeedNotFound(ctx, sqlite.MarkFeedNotFoundParams{
+		BrokenAfterSeconds: int64(arg.BrokenAfterSeconds),
+		ID:                 arg.ID,
+	})
+	return feedFromSQLite(feed), err
+}
+
+func (s *SQLiteStore) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	feedFollow, err := s.q.CreateFeedFollow(ctx, sqlite.CreateFeedFollowParams(arg))
+	return FeedFollow(feedFollow), err
+}
+
+func (s *SQLiteStore) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	return s.q.DeleteFeedFollow(ctx, sqlite.DeleteFeedFollowParams(arg))
+}
+
+func (s *SQLiteStore) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
+	feedFollows, err := s.q.GetFeedFollowsForUser(ctx, userID)
+	var result []GetFeedFollowsForUserRow
+	for _, feedFollow := range feedFollows {
+		result = append(result, GetFeedFollowsForUserRow(feedFollow))
+	}
+	return result, err
+}
+
+func (s *SQLiteStore) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	return s.q.MoveFeedFollows(ctx, sqlite.MoveFeedFollowsParams(arg))
+}
+
+func (s *SQLiteStore) UpdateFeedFollow(ctx context.Context, arg UpdateFeedFollowParams) (FeedFollow, error) {
+	feedFollow, err := s.q.UpdateFeedFollow(ctx, sqlite.UpdateFeedFollowParams(arg))
+	return FeedFollow(feedFollow), err
+}
+
+func (s *SQLiteStore) SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (FeedFollow, error) {
+	feedFollow, err := s.q.SetFeedFollowCategory(ctx, sqlite.SetFeedFollowCategoryParams(arg))
+	return FeedFollow(feedFollow), err
+}
+
+func (s *SQLiteStore) CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error) {
+	category, err := s.q.CreateCategory(ctx, sqlite.CreateCategoryParams(arg))
+	return Category(category), err
+}
+
+func (s *SQLiteStore) GetCategoriesForUser(ctx context.Context, userID uuid.UUID) ([]Category, error) {
+	categories, err := s.q.GetCategoriesForUser(ctx, userID)
+	var result []Category
+	for _, category := range categories {
+		result = append(result, Category(category))
+	}
+	return result, err
+}
+
+func (s *SQLiteStore) RenameCategory(ctx context.Context, arg RenameCategoryParams) (Category, error) {
+	category, err := s.q.RenameCategory(ctx, sqlite.RenameCategoryParams(arg))
+	return Category(category), err
+}
+
+func (s *SQLiteStore) DeleteCategory(ctx context.Context, arg DeleteCategoryParams) (int64, error) {
+	return s.q.DeleteCategory(ctx, sqlite.DeleteCategoryParams(arg))
+}
+
+func (s *SQLiteStore) CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error) {
+	rule, err := s.q.CreateFilterRule(ctx, sqlite.CreateFilterRuleParams(arg))
+	return FilterRule(rule), err
+}
+
+func (s *SQLiteStore) GetFilterRulesForUser(ctx context.Context, userID uuid.UUID) ([]FilterRule, error) {
+	rules, err := s.q.GetFilterRulesForUser(ctx, userID)
+	return filterRules(rules), err
+}
+
+func (s *SQLiteStore) UpdateFilterRule(ctx context.Context, arg UpdateFilterRuleParams) (FilterRule, error) {
+	rule, err := s.q.UpdateFilterRule(ctx, sqlite.UpdateFilterRuleParams(arg))
+	return FilterRule(rule), err
+}
+
+func (s *SQLiteStore) DeleteFilterRule(ctx context.Context, arg DeleteFilterRuleParams) (int64, error) {
+	return s.q.DeleteFilterRule(ctx, sqlite.DeleteFilterRuleParams(arg))
+}
+
+func (s *SQLiteStore) GetHideRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error) {
+	rules, err := s.q.GetHideRulesForFeed(ctx, feedID)
+	return filterRules(rules), err
+}
+
+func (s *SQLiteStore) CreatePostRuleMatch(ctx context.Context, arg CreatePostRuleMatchParams) error {
+	return s.q.CreatePostRuleMatch(ctx, sqlite.CreatePostRuleMatchParams(arg))
+}
+
+// CreatePostRuleMatches records the matches one by one in a single
+// transaction, like CreatePosts.
+func (s *SQLiteStore) CreatePostRuleMatches(ctx context.Context, matches []CreatePostRuleMatchParams) error {
+	return s.InTx(ctx, func(tx Store) error {
+		for _, match := range matches {
+			if err := tx.CreatePostRuleMatch(ctx, match); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) DeletePostRuleMatches(ctx context.Context, ruleID uuid.UUID) error {
+	return s.q.DeletePostRuleMatches(ctx, ruleID)
+}
+
+func filterRules(rules []sqlite.FilterRule) []FilterRule {
+	var result []FilterRule
+	for _, rule := range rules {
+		result = append(result, FilterRule(rule))
+	}
+	return result
+}
+
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	webhook, err := s.q.CreateWebhook(ctx, sqlite.CreateWebhookParams(arg))
+	return Webhook(webhook), err
+}
+
+func (s *SQLiteStore) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	webhooks, err := s.q.GetWebhooksForUser(ctx, userID)
+	return webhooksFromSQLite(webhooks), err
+}
+
+func (s *SQLiteStore) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	webhook, err := s.q.GetWebhookByID(ctx, id)
+	return Webhook(webhook), err
+}
+
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	return s.q.DeleteWebhook(ctx, sqlite.DeleteWebhookParams(arg))
+}
+
+func (s *SQLiteStore) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	webhooks, err := s.q.GetWebhooksForFeed(ctx, feedID)
+	return webhooksFromSQLite(webhooks), err
+}
+
+func webhooksFromSQLite(webhooks []sqlite.Webhook) []Webhook {
+	var result []Webhook
+	for _, webhook := range webhooks {
+		result = append(result, Webhook(webhook))
+	}
+	return result
+}
+
+func (s *SQLiteStore) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	return s.q.CreateWebhookDelivery(ctx, sqlite.CreateWebhookDeliveryParams(arg))
+}
+
+// CreateWebhookDeliveries queues the deliveries one by one in a single
+// transaction, like CreatePosts.
+func (s *SQLiteStore) CreateWebhookDeliveries(ctx context.Context, deliveries []CreateWebhookDeliveryParams) error {
+	return s.InTx(ctx, func(tx Store) error {
+		for _, delivery := range deliveries {
+			if err := tx.CreateWebhookDelivery(ctx, delivery); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	deliveries, err := s.q.ClaimWebhookDeliveries(ctx, sqlite.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    arg.LeaseUntil,
+		Now:           arg.Now,
+		MaxDeliveries: int64(arg.MaxDeliveries),
+	})
+	return webhookDeliveriesFromSQLite(deliveries), err
+}
+
+func (s *SQLiteStore) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	return s.q.RecordWebhookAttempt(ctx, sqlite.RecordWebhookAttemptParams{
+		Status:         arg.Status,
+		NextAttemptAt:  arg.NextAttemptAt,
+		LastAttemptAt:  arg.LastAttemptAt,
+		ResponseStatus: sql.NullInt64{Int64: int64(arg.ResponseStatus.Int32), Valid: arg.ResponseStatus.Valid},
+		Error:          arg.Error,
+		ID:             arg.ID,
+	})
+}
+
+func (s *SQLiteStore) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	deliveries, err := s.q.GetWebhookDeliveries(ctx, sqlite.GetWebhookDeliveriesParams{
+		WebhookID: arg.WebhookID,
+		Limit:     int64(arg.Limit),
+	})
+	return webhookDeliveriesFromSQLite(deliveries), err
+}
+
+func webhookDeliveriesFromSQLite(deliveries []sqlite.WebhookDelivery) []WebhookDelivery {
+	var result []WebhookDelivery
+	for _, delivery := range deliveries {
+		result = append(result, WebhookDelivery{
+			ID:             delivery.ID,
+			CreatedAt:      delivery.CreatedAt,
+			UpdatedAt:      delivery.UpdatedAt,
+			WebhookID:      delivery.WebhookID,
+			PostID:         delivery.PostID,
+			Payload:        delivery.Payload,
+			Status:         delivery.Status,
+			Attempts:       int32(delivery.Attempts),
+			NextAttemptAt:  delivery.NextAttemptAt,
+			LastAttemptAt:  delivery.LastAttemptAt,
+			ResponseStatus: sql.NullInt32{Int32: int32(delivery.ResponseStatus.Int64), Valid: delivery.ResponseStatus.Valid},
+			Error:          delivery.Error,
+		})
+	}
+	return result
+}
+
+func (s *SQLiteStore) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	post, err := s.q.CreatePost(ctx, sqlite.CreatePostParams{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Title:          arg.Title,
+		Url:            arg.Url,
+		Description:    arg.Description,
+		PublishedAt:    arg.PublishedAt,
+		FeedID:         arg.FeedID,
+		ContentHtml:    arg.ContentHtml,
+		Authors:        string(jsonArray(arg.Authors)),
+		Categories:     string(jsonArray(arg.Categories)),
+		Guid:           arg.Guid,
+		Enclosures:     string(jsonArray(arg.Enclosures)),
+		DescriptionRaw: arg.DescriptionRaw,
+		ContentHtmlRaw: arg.ContentHtmlRaw,
+		ContentText:    arg.ContentText,
+		CanonicalUrl:   arg.CanonicalUrl,
+		TitleKey:       arg.TitleKey,
+		Simhash:        arg.Simhash,
+		ClusterID:      postClusterID(arg),
+	})
+	return postFromSQLite(post), err
+}
+
+// CreatePosts inserts the posts one by one in a single transaction. SQLite
+// runs in-process, so unlike with Postgres the statements cost no round-trips.
+func (s *SQLiteStore) CreatePosts(ctx context.Context, posts []CreatePostParams) ([]uuid.UUID, error) {
+	var created []uuid.UUID
+	err := s.InTx(ctx, func(tx Store) error {
+		created = nil
+		for _, post := range posts {
+			_, err := tx.CreatePost(ctx, post)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			created = append(created, post.ID)
+		}
+		return nil
+	})
+	return created, err
+}
+
+func (s *SQLiteStore) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
+	posts, err := s.q.GetPostsForUser(ctx, sqlite.GetPostsForUserParams{
+		UserID:     arg.UserID,
+		HasMedia:   arg.HasMedia,
+		FeedID:     arg.FeedID,
+		CategoryID: arg.CategoryID,
+		Category:   arg.Category,
+		Collapse:   arg.Collapse,
+		Limit:      int64(arg.Limit),
+	})
+	var result []Post
+	for _, post := range posts {
+		result = append(result, postFromSQLite(post))
+	}
+	return result, err
+}
+
+func (s *SQLiteStore) GetClusterPostsForUser(ctx context.Context, arg GetClusterPostsForUserParams) ([]Post, error) {
+	posts, err := s.q.GetClusterPostsForUser(ctx, sqlite.GetClusterPostsForUserParams(arg))
+	var result []Post
+	for _, post := range posts {
+		result = append(result, postFromSQLite(post))
+	}
+	return result, err
+}
+
+// GetPostFingerprintCandidates looks the candidates up one post at a time,
+// as the SQLite queries can't take lists.
+func (s *SQLiteStore) GetPostFingerprintCandidates(ctx context.Context, since time.Time, posts []CreatePostParams) ([]GetPostFingerprintCandidatesRow, error) {
+	seen := map[uuid.UUID]bool{}
+	var result []GetPostFingerprintCandidatesRow
+	for _, post := range posts {
+		bands := [4]int64{-1, -1, -1, -1}
+		if post.Simhash != 0 {
+			bands = simhashBands(post.Simhash)
+		}
+		rows, err := s.q.GetPostFingerprintCandidates(ctx, sqlite.GetPostFingerprintCandidatesParams{
+			Since:        since,
+			CanonicalUrl: post.CanonicalUrl,
+			TitleKey:     post.TitleKey,
+			SimhashBand0: bands[0],
+			SimhashBand1: bands[1],
+			SimhashBand2: bands[2],
+			SimhashBand3: bands[3],
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if !seen[row.ID] {
+				seen[row.ID] = true
+				result = append(result, GetPostFingerprintCandidatesRow(row))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *SQLiteStore) MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error {
+	return s.q.MovePostsToFeed(ctx, sqlite.MovePostsToFeedParams(arg))
+}
+
+func (s *SQLiteStore) UserFollowsPost(ctx context.Context, arg UserFollowsPostParams) (bool, error) {
+	follows, err := s.q.UserFollowsPost(ctx, sqlite.UserFollowsPostParams(arg))
+	return follows == 1, err
+}
+
+func (s *SQLiteStore) StarPost(ctx context.Context, arg StarPostParams) error {
+	return s.q.StarPost(ctx, sqlite.StarPostParams(arg))
+}
+
+func (s *SQLiteStore) UnstarPost(ctx context.Context, arg UnstarPostParams) error {
+	return s.q.UnstarPost(ctx, sqlite.UnstarPostParams(arg))
+}
+
+func (s *SQLiteStore) DeleteOldPosts(ctx context.Context, arg DeleteOldPostsParams) (int64, error) {
+	return s.q.DeleteOldPosts(ctx, sqlite.DeleteOldPostsParams{
+		MaxAgeSeconds: int64(arg.MaxAgeSeconds),
+		BatchSize:     int64(arg.BatchSize),
+	})
+}
+
+func (s *SQLiteStore) DeleteExcessPosts(ctx context.Context, arg DeleteExcessPostsParams) (int64, error) {
+	return s.q.DeleteExcessPosts(ctx, sqlite.DeleteExcessPostsParams{
+		MaxPosts:  arg.MaxPosts,
+		BatchSize: int64(arg.BatchSize),
+	})
+}
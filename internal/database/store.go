@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	moderncsqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Store is everything the API handlers and the scraper need from the
+// database. PostgresStore implements it on top of Postgres, SQLiteStore on
+// top of SQLite and MemoryStore keeps the data in memory for tests.
+type Store interface {
+	UserStore
+	FeedStore
+	FeedFollowStore
+	CategoryStore
+	FilterRuleStore
+	WebhookStore
+	PostStore
+
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+
+	// InTx runs fn against a Store whose operations all belong to a single
+	// transaction, committed only if fn returns nil.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	GetUserByApiKey(ctx context.Context, apiKey string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	// RotateFeedToken replaces the token of the user's outbound feeds, so
+	// that the old feed URLs stop working.
+	RotateFeedToken(ctx context.Context, arg RotateFeedTokenParams) (User, error)
+}
+
+type FeedStore interface {
+	CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error)
+	DeleteFeed(ctx context.Context, id uuid.UUID) error
+	GetFeedByURL(ctx context.Context, arg GetFeedByURLParams) (Feed, error)
+	GetFeeds(ctx context.Context) ([]Feed, error)
+	CreateFeedURLAlias(ctx context.Context, arg CreateFeedURLAliasParams) error
+	MoveFeedURLAliases(ctx context.Context, arg MoveFeedURLAliasesParams) error
+	UpdateFeedRequestHeaders(ctx context.Context, arg UpdateFeedRequestHeadersParams) (Feed, error)
+	UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error)
+	SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error)
+
+	GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error)
+	MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error)
+	// MarkFeedGone, MarkFeedHealthy and MarkFeedNotFound leave a paused
+	// feed as it is; MarkFeedNotFound returns sql.ErrNoRows for it.
+	MarkFeedGone(ctx context.Context, id uuid.UUID) error
+	MarkFeedHealthy(ctx context.Context, id uuid.UUID) error
+	MarkFeedNotFound(ctx context.Context, arg MarkFeedNotFoundParams) (Feed, error)
+}
+
+type FeedFollowStore interface {
+	CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error)
+	DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error
+	GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error)
+	MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error
+	// UpdateFeedFollow returns sql.ErrNoRows if the follow doesn't belong to
+	// the user.
+	UpdateFeedFollow(ctx context.Context, arg UpdateFeedFollowParams) (FeedFollow, error)
+	// SetFeedFollowCategory returns sql.ErrNoRows if the follow or the
+	// category doesn't belong to the user.
+	SetFeedFollowCategory(ctx context.Context, arg SetFeedFollowCategoryParams) (FeedFollow, error)
+}
+
+type CategoryStore interface {
+	CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error)
+	GetCategoriesForUser(ctx context.Context, userID uuid.UUID) ([]Category, error)
+	RenameCategory(ctx context.Context, arg RenameCategoryParams) (Category, error)
+	// DeleteCategory returns how many categories it deleted, 0 if the
+	// category doesn't belong to the user.
+	DeleteCategory(ctx context.Context, arg DeleteCategoryParams) (int64, error)
+}
+
+type FilterRuleStore interface {
+	CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error)
+	GetFilterRulesForUser(ctx context.Context, userID uuid.UUID) ([]FilterRule, error)
+	// UpdateFilterRule returns sql.ErrNoRows if the rule doesn't belong to
+	// the user.
+	UpdateFilterRule(ctx context.Context, arg UpdateFilterRuleParams) (FilterRule, error)
+	// DeleteFilterRule returns how many rules it deleted, 0 if the rule
+	// doesn't belong to the user.
+	DeleteFilterRule(ctx context.Context, arg DeleteFilterRuleParams) (int64, error)
+	// GetHideRulesForFeed returns the hide rules of the feed's followers
+	// that apply to its posts.
+	GetHideRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error)
+	// CreatePostRuleMatch records that a hide rule matched a post. It does
+	// nothing if the rule or the post doesn't exist.
+	CreatePostRuleMatch(ctx context.Context, arg CreatePostRuleMatchParams) error
+	// CreatePostRuleMatches records a batch of matches at once, skipping
+	// them as CreatePostRuleMatch does.
+	CreatePostRuleMatches(ctx context.Context, matches []CreatePostRuleMatchParams) error
+	DeletePostRuleMatches(ctx context.Context, ruleID uuid.UUID) error
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error)
+	GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error)
+	// DeleteWebhook returns how many webhooks it deleted, 0 if the webhook
+	// doesn't belong to the user.
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error)
+	// GetWebhooksForFeed returns the webhooks the new posts of a feed are
+	// sent to.
+	GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error)
+	// CreateWebhookDelivery queues a post for a webhook. It does nothing if
+	// the post doesn't exist, a hide rule of the webhook's owner matched it
+	// or it is already queued.
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error
+	// CreateWebhookDeliveries queues a batch of deliveries at once, skipping
+	// them as CreateWebhookDelivery does.
+	CreateWebhookDeliveries(ctx context.Context, deliveries []CreateWebhookDeliveryParams) error
+	// ClaimWebhookDeliveries leases up to max_deliveries pending deliveries
+	// that are due.
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error
+	GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error)
+}
+
+type PostStore interface {
+	CreatePost(ctx context.Context, arg CreatePostParams) (Post, error)
+	// CreatePosts inserts a batch of posts, skipping those whose URL is
+	// already known in their feed, and returns the IDs of the new ones.
+	CreatePosts(ctx context.Context, posts []CreatePostParams) ([]uuid.UUID, error)
+	GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error)
+	GetClusterPostsForUser(ctx context.Context, arg GetClusterPostsForUserParams) ([]Post, error)
+	// GetPostFingerprintCandidates returns the fingerprints of the posts
+	// stored since the given time that share a canonical URL, a title key or
+	// a simhash band with one of posts, to match these against.
+	GetPostFingerprintCandidates(ctx context.Context, since time.Time, posts []CreatePostParams) ([]GetPostFingerprintCandidatesRow, error)
+	MovePostsToFeed(ctx context.Context, arg MovePostsToFeedParams) error
+	UserFollowsPost(ctx context.Context, arg UserFollowsPostParams) (bool, error)
+	StarPost(ctx context.Context, arg StarPostParams) error
+	UnstarPost(ctx context.Context, arg UnstarPostParams) error
+	// DeleteOldPosts and DeleteExcessPosts enforce the retention policy, a
+	// batch at a time, and return how many posts they deleted. Starred
+	// posts are never deleted.
+	DeleteOldPosts(ctx context.Context, arg DeleteOldPostsParams) (int64, error)
+	DeleteExcessPosts(ctx context.Context, arg DeleteExcessPostsParams) (int64, error)
+}
+
+// NewToken returns a random secret of 64 hex characters, such as an api
+// key, a feed token or the key to sign the payloads of a webhook with. It
+// has the same shape as the api keys generated by Postgres.
+func NewToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// ErrUniqueViolation is returned by MemoryStore when an insert or update
+// conflicts with a unique constraint.
+var ErrUniqueViolation = errors.New("duplicate key value violates unique constraint")
+
+// ErrForeignKeyViolation is returned by MemoryStore when a row references a
+// user, feed or post that doesn't exist.
+var ErrForeignKeyViolation = errors.New("insert or update violates foreign key constraint")
+
+// IsForeignKeyViolation reports whether err was caused by a reference to a
+// row that doesn't exist, whichever Store returned it.
+func IsForeignKeyViolation(err error) bool {
+	if errors.Is(err, ErrForeignKeyViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+	var sqliteErr *moderncsqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint,
+// whichever Store returned it.
+func IsUniqueViolation(err error) bool {
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *moderncsqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
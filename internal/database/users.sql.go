@@ -13,9 +13,9 @@ import (
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
-RETURNING id, created_at, updated_at, name, api_key
+INSERT INTO users (id, created_at, updated_at, name, api_key, feed_token)
+VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'), $5)
+RETURNING id, created_at, updated_at, name, api_key, feed_token
 `
 
 type CreateUserParams struct {
@@ -23,6 +23,7 @@ type CreateUserParams struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Name      string
+	FeedToken string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -31,6 +32,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.FeedToken,
 	)
 	var i User
 	err := row.Scan(
@@ -39,12 +41,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Name,
 		&i.ApiKey,
+		&i.FeedToken,
 	)
 	return i, err
 }
 
 const getUserByApiKey = `-- name: GetUserByApiKey :one
-SELECT id, created_at, updated_at, name, api_key FROM users
+SELECT id, created_at, updated_at, name, api_key, feed_token FROM users
 WHERE api_key = $1
 `
 
@@ -57,6 +60,53 @@ func (q *Queries) GetUserByApiKey(ctx context.Context, apiKey string) (User, err
 		&i.UpdatedAt,
 		&i.Name,
 		&i.ApiKey,
+		&i.FeedToken,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, name, api_key, feed_token FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKey,
+		&i.FeedToken,
+	)
+	return i, err
+}
+
+const rotateFeedToken = `-- name: RotateFeedToken :one
+UPDATE users
+SET feed_token = $1,
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, name, api_key, feed_token
+`
+
+type RotateFeedTokenParams struct {
+	FeedToken string
+	ID        uuid.UUID
+}
+
+func (q *Queries) RotateFeedToken(ctx context.Context, arg RotateFeedTokenParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, rotateFeedToken, arg.FeedToken, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKey,
+		&i.FeedToken,
 	)
 	return i, err
 }
@@ -0,0 +1,388 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending'
+    AND d.next_attempt_at <= $2
+    ORDER BY d.next_attempt_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, webhook_id, post_id, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil    time.Time
+	Now           time.Time
+	MaxDeliveries int32
+}
+
+// Pushes the next attempt of the pending deliveries that are due to
+// lease_until, so that other workers leave them alone while they are sent.
+// A delivery whose worker died is retried once the lease runs out.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, category_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, user_id, url, secret, feed_id, category_id
+`
+
+type CreateWebhookParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	FeedID     uuid.NullUUID
+	CategoryID uuid.NullUUID
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.FeedID,
+		arg.CategoryID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.FeedID,
+		&i.CategoryID,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, payload, next_attempt_at)
+SELECT $1::uuid, $2::timestamp, $2::timestamp,
+    webhooks.id, posts.id, $3::text, $2::timestamp
+FROM webhooks, posts
+WHERE webhooks.id = $4 AND posts.id = $5
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = webhooks.user_id AND r.action = 'hide'
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING
+`
+
+type CreateWebhookDeliveryParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Payload   string
+	WebhookID uuid.UUID
+	PostID    uuid.UUID
+}
+
+// Nothing is queued for posts that weren't stored, being duplicates, nor for
+// those a hide rule of the webhook's owner matched.
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.CreatedAt,
+		arg.Payload,
+		arg.WebhookID,
+		arg.PostID,
+	)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebhookByID = `-- name: GetWebhookByID :one
+
+SELECT id, created_at, updated_at, user_id, url, secret, feed_id, category_id FROM webhooks WHERE id = $1
+`
+
+func (q *Queries) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookByID, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.FeedID,
+		&i.CategoryID,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+
+SELECT id, created_at, updated_at, webhook_id, post_id, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, error FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Limit     int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForFeed = `-- name: GetWebhooksForFeed :many
+
+SELECT webhooks.id, webhooks.created_at, webhooks.updated_at, webhooks.user_id, webhooks.url, webhooks.secret, webhooks.feed_id, webhooks.category_id FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+WHERE feed_follows.feed_id = $1
+AND feed_follows.notify = 'all'
+AND (webhooks.feed_id IS NULL OR webhooks.feed_id = $1)
+AND (webhooks.category_id IS NULL OR webhooks.category_id = feed_follows.category_id)
+ORDER BY webhooks.created_at, webhooks.id
+`
+
+// Returns the webhooks the new posts of a feed go to: those of the users who
+// follow it and want to be notified of its posts, scoped to the feed, to the
+// category the user filed it into or to neither.
+func (q *Queries) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForFeed, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.FeedID,
+			&i.CategoryID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForUser = `-- name: GetWebhooksForUser :many
+
+SELECT id, created_at, updated_at, user_id, url, secret, feed_id, category_id FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.FeedID,
+			&i.CategoryID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertWebhookDeliveriesJSON = `-- name: InsertWebhookDeliveriesJSON :exec
+
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, payload, next_attempt_at)
+SELECT d.id, d.created_at, d.created_at, webhooks.id, posts.id, d.payload, d.created_at
+FROM jsonb_to_recordset($1::jsonb) AS d(
+    id UUID,
+    created_at TIMESTAMP,
+    webhook_id UUID,
+    post_id UUID,
+    payload TEXT
+)
+JOIN webhooks ON webhooks.id = d.webhook_id
+JOIN posts ON posts.id = d.post_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = webhooks.user_id AND r.action = 'hide'
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING
+`
+
+// Queues a whole batch of deliveries, given as a JSON array of objects, in
+// one statement, skipping them as CreateWebhookDelivery does.
+func (q *Queries) InsertWebhookDeliveriesJSON(ctx context.Context, deliveries json.RawMessage) error {
+	_, err := q.db.ExecContext(ctx, insertWebhookDeliveriesJSON, deliveries)
+	return err
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+
+UPDATE webhook_deliveries
+SET status = $1,
+attempts = attempts + 1,
+next_attempt_at = $2,
+last_attempt_at = $3,
+response_status = $4,
+error = $5,
+updated_at = NOW()
+WHERE id = $6
+`
+
+type RecordWebhookAttemptParams struct {
+	Status         string
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastAttemptAt,
+		arg.ResponseStatus,
+		arg.Error,
+		arg.ID,
+	)
+	return err
+}
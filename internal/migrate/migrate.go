@@ -0,0 +1,388 @@
+// Package migrate applies the goose-style migrations in sql/schema (and
+// sql/sqlite/schema) from an fs.FS. It keeps track of applied versions in the
+// same goose_db_version table as the goose CLI, so both can be used on the
+// same database.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDatabaseAhead is returned when the database has migrations applied that
+// this binary doesn't know about, i.e. it was migrated by a newer release.
+var ErrDatabaseAhead = errors.New("database schema is newer than this binary")
+
+const versionTable = "goose_db_version"
+
+// Dialect holds the SQL that differs between the supported databases.
+type Dialect struct {
+	createVersionTable string
+	insertVersion      string
+	deleteVersion      string
+	// lockKey is used with pg_advisory_lock so that only one instance
+	// migrates at a time. Zero means the dialect has no advisory locks.
+	lockKey int64
+}
+
+var (
+	Postgres = Dialect{
+		createVersionTable: `CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+			id serial NOT NULL,
+			version_id bigint NOT NULL,
+			is_applied boolean NOT NULL,
+			tstamp timestamp NULL default now(),
+			PRIMARY KEY(id)
+		)`,
+		insertVersion: `INSERT INTO ` + versionTable + ` (version_id, is_applied) VALUES ($1, $2)`,
+		deleteVersion: `DELETE FROM ` + versionTable + ` WHERE version_id = $1`,
+		lockKey:       5887940537704921958,
+	}
+	SQLite = Dialect{
+		createVersionTable: `CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			version_id INTEGER NOT NULL,
+			is_applied INTEGER NOT NULL,
+			tstamp TIMESTAMP DEFAULT (datetime('now'))
+		)`,
+		insertVersion: `INSERT INTO ` + versionTable + ` (version_id, is_applied) VALUES (?, ?)`,
+		deleteVersion: `DELETE FROM ` + versionTable + ` WHERE version_id = ?`,
+	}
+)
+
+// Migration is a single versioned file, split into its Up and Down sections.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New reads the migrations at the root of fsys. Files must be named
+// <version>_<name>.sql and contain "-- +goose Up" and "-- +goose Down"
+// sections.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int64]string{}
+	for _, name := range entries {
+		versionStr, _, ok := strings.Cut(path.Base(name), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", name)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, versionStr)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := parseMigration(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			Up:      up,
+			Down:    down,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+func parseMigration(content string) (up, down string, err error) {
+	var section *strings.Builder
+	var upSQL, downSQL strings.Builder
+	foundUp := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				section = &upSQL
+				foundUp = true
+			case "Down":
+				section = &downSQL
+			case "StatementBegin", "StatementEnd":
+				// Each section runs as a single Exec, so statement
+				// boundaries don't matter
+			default:
+				return "", "", fmt.Errorf("unsupported annotation %q", line)
+			}
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+			section.WriteString("\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	if !foundUp {
+		return "", "", errors.New("missing -- +goose Up annotation")
+	}
+	return upSQL.String(), downSQL.String(), nil
+}
+
+// Latest returns the version of the newest known migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// conn returns a connection holding the migration lock, so that instances
+// starting at the same time don't apply the same migration twice.
+func (m *Migrator) conn(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.dialect.lockKey == 0 {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", m.dialect.lockKey)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("couldn't acquire migration lock: %w", err)
+	}
+	release := func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", m.dialect.lockKey)
+		conn.Close()
+	}
+	return conn, release, nil
+}
+
+// applied returns the applied versions along with when they were applied.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	_, err := conn.ExecContext(ctx, m.dialect.createVersionTable)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create %s: %w", versionTable, err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM "+versionTable+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	empty := true
+	for rows.Next() {
+		empty = false
+		var version int64
+		var isApplied bool
+		var tstamp sql.NullTime
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, err
+		}
+		// Version 0 is the marker row goose inserts when creating the table
+		if version == 0 {
+			continue
+		}
+		if isApplied {
+			applied[version] = tstamp.Time
+		} else {
+			delete(applied, version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Like goose, mark a freshly created table with version 0
+	if empty {
+		_, err = conn.ExecContext(ctx, m.dialect.insertVersion, 0, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
+
+func (m *Migrator) checkNotAhead(applied map[int64]time.Time) error {
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] && version > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, latest known migration is %d", ErrDatabaseAhead, version, m.Latest())
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, query string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(query) != "" {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies all pending migrations in order and returns how many ran.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	err = m.checkNotAhead(applied)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err = m.run(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, m.dialect.insertVersion, migration.Version, true)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the most recently applied migration. It returns the
+// migration that was rolled back, or nil if there was nothing to roll back.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkNotAhead(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err = m.run(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, m.dialect.deleteVersion, migration.Version)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rollback of %s failed: %w", migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Status lists every known migration and when it was applied, if it was.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, m.checkNotAhead(applied)
+}
+
+// Pending returns how many known migrations haven't been applied yet. It
+// fails with ErrDatabaseAhead if the database is newer than the binary.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
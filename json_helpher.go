@@ -7,7 +7,13 @@ import (
 )
 
 func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
-	w.Header().Set("Content-type", "application/json")
+	respondWithJSONType(w, status, "application/json", payload)
+}
+
+// respondWithJSONType responds with a JSON document of a more specific type,
+// such as a JSON Feed.
+func respondWithJSONType(w http.ResponseWriter, status int, contentType string, payload interface{}) {
+	w.Header().Set("Content-type", contentType)
 	dat, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -10,21 +14,73 @@ import (
 	"time"
 
 	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/L-PDufour/Blog-aggr/internal/migrate"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 type apiConfig struct {
-	DB *database.Queries
+	DB database.Store
 }
 
 type FeedFollow struct {
+	ID         uuid.UUID `json:"id"`
+	FeedID     uuid.UUID `json:"feed_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	FeedStatus string    `json:"feed_status,omitempty"`
+	// CategoryID is the user's category the follow is filed into, if any.
+	CategoryID   *uuid.UUID `json:"category_id"`
+	CategoryName *string    `json:"category_name,omitempty"`
+	// Title is the custom title the user gave the feed, or else its name.
+	Title       string  `json:"title,omitempty"`
+	CustomTitle *string `json:"custom_title"`
+	Muted       bool    `json:"muted"`
+	Notify      string  `json:"notify"`
+}
+
+type Category struct {
 	ID        uuid.UUID `json:"id"`
-	FeedID    uuid.UUID `json:"feed_id"`
-	UserID    uuid.UUID `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type FilterRule struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FeedID      *uuid.UUID `json:"feed_id"`
+	Field       string     `json:"field"`
+	PatternType string     `json:"pattern_type"`
+	Pattern     string     `json:"pattern"`
+	Action      string     `json:"action"`
+}
+
+type Webhook struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Url        string     `json:"url"`
+	Secret     string     `json:"secret,omitempty"` // only returned when the webhook is created
+	FeedID     *uuid.UUID `json:"feed_id"`
+	CategoryID *uuid.UUID `json:"category_id"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	PostID         uuid.UUID  `json:"post_id"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int32     `json:"response_status"`
+	Error          *string    `json:"error"`
 }
 
 type User struct {
@@ -33,6 +89,8 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Name      string    `json:"name"`
 	ApiKey    string    `json:"api_key"`
+	// FeedToken authenticates the user's outbound Atom and RSS feeds.
+	FeedToken string `json:"feed_token"`
 }
 
 type Post struct {
@@ -44,10 +102,57 @@ type Post struct {
 	Description sql.NullString `json:"description"`
 	PublishedAt sql.NullTime   `json:"published_at"`
 	FeedID      uuid.UUID      `json:"feed_id"`
+	ContentHTML *string        `json:"content_html"`
+	ContentText *string        `json:"content_text"`
+	Authors     []string       `json:"authors"`
+	Categories  []string       `json:"categories"`
+	GUID        *string        `json:"guid"`
+	Enclosures  []Enclosure    `json:"enclosures"`
+	// ClusterID is shared by the posts of different feeds that tell the same
+	// story.
+	ClusterID uuid.UUID `json:"cluster_id"`
+	// Sources lists every post of the cluster when posts are collapsed.
+	Sources []PostSource `json:"sources,omitempty"`
+	// Highlighted and Read are set by the user's filter rules.
+	Highlighted bool `json:"highlighted"`
+	Read        bool `json:"read"`
+}
+
+// PostSource is one of the posts a collapsed post stands for.
+type PostSource struct {
+	PostID      uuid.UUID    `json:"post_id"`
+	FeedID      uuid.UUID    `json:"feed_id"`
+	Title       string       `json:"title"`
+	Url         string       `json:"url"`
+	PublishedAt sql.NullTime `json:"published_at"`
+}
+
+// Enclosure is a media file attached to a post, such as a podcast episode.
+// It is stored as JSON in posts.enclosures in this same shape.
+type Enclosure struct {
+	URL             string `json:"url"`
+	MimeType        string `json:"mime_type"`
+	Length          *int64 `json:"length"`
+	DurationSeconds *int64 `json:"duration_seconds"`
 }
 
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
+// Feed lifecycle states, stored in feeds.status. Paused and gone feeds are no
+// longer fetched; broken feeds are still fetched in case they come back.
+const (
+	feedStatusActive = "active"
+	feedStatusPaused = "paused"
+	feedStatusGone   = "gone"
+	feedStatusBroken = "broken"
+)
+
+// Notification preferences of a follow, stored in feed_follows.notify.
+const (
+	notifyAll  = "all"
+	notifyNone = "none"
+)
+
 type Feed struct {
 	ID            uuid.UUID  `json:"id"`
 	CreatedAt     time.Time  `json:"created_at"`
@@ -56,6 +161,8 @@ type Feed struct {
 	Url           string     `json:"url"`
 	UserID        uuid.UUID  `json:"user_id"`
 	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	Status        string     `json:"status"`
+	NotFoundSince *time.Time `json:"not_found_since"`
 }
 
 func convertNullTimeToTimePtr(nt sql.NullTime) *time.Time {
@@ -65,22 +172,104 @@ func convertNullTimeToTimePtr(nt sql.NullTime) *time.Time {
 	return nil
 }
 
-func databaseFeedFollowsToFeedFollows(feedFollows []database.FeedFollow) []FeedFollow {
+func databaseFeedFollowsToFeedFollows(feedFollows []database.GetFeedFollowsForUserRow) []FeedFollow {
 	result := make([]FeedFollow, len(feedFollows))
 	for i, feedFollow := range feedFollows {
-		result[i] = databaseFeedFollowToFeedFollow(feedFollow)
+		result[i] = FeedFollow{
+			ID:           feedFollow.ID,
+			FeedID:       feedFollow.FeedID,
+			UserID:       feedFollow.UserID,
+			CreatedAt:    feedFollow.CreatedAt,
+			UpdatedAt:    feedFollow.UpdatedAt,
+			FeedStatus:   feedFollow.FeedStatus,
+			CategoryID:   convertNullUUIDToUUIDPtr(feedFollow.CategoryID),
+			CategoryName: convertNullStringToStringPtr(feedFollow.CategoryName),
+			Title:        feedFollow.FeedName,
+			CustomTitle:  convertNullStringToStringPtr(feedFollow.CustomTitle),
+			Muted:        feedFollow.Muted,
+			Notify:       feedFollow.Notify,
+		}
+		if feedFollow.CustomTitle.Valid {
+			result[i].Title = feedFollow.CustomTitle.String
+		}
 	}
 	return result
 }
 
 func databaseFeedFollowToFeedFollow(feedFollow database.FeedFollow) FeedFollow {
 	return FeedFollow{
-		ID:        feedFollow.ID,
-		FeedID:    feedFollow.FeedID,
-		UserID:    feedFollow.UserID,
-		CreatedAt: feedFollow.CreatedAt,
-		UpdatedAt: feedFollow.UpdatedAt,
+		ID:          feedFollow.ID,
+		FeedID:      feedFollow.FeedID,
+		UserID:      feedFollow.UserID,
+		CreatedAt:   feedFollow.CreatedAt,
+		UpdatedAt:   feedFollow.UpdatedAt,
+		CategoryID:  convertNullUUIDToUUIDPtr(feedFollow.CategoryID),
+		CustomTitle: convertNullStringToStringPtr(feedFollow.CustomTitle),
+		Muted:       feedFollow.Muted,
+		Notify:      feedFollow.Notify,
+	}
+}
+
+func databaseCategoryToCategory(category database.Category) Category {
+	return Category{
+		ID:        category.ID,
+		CreatedAt: category.CreatedAt,
+		UpdatedAt: category.UpdatedAt,
+		Name:      category.Name,
+	}
+}
+
+func databaseFilterRuleToFilterRule(rule database.FilterRule) FilterRule {
+	return FilterRule{
+		ID:          rule.ID,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+		FeedID:      convertNullUUIDToUUIDPtr(rule.FeedID),
+		Field:       rule.Field,
+		PatternType: rule.PatternType,
+		Pattern:     rule.Pattern,
+		Action:      rule.Action,
+	}
+}
+
+func databaseWebhookToWebhook(webhook database.Webhook) Webhook {
+	return Webhook{
+		ID:         webhook.ID,
+		CreatedAt:  webhook.CreatedAt,
+		UpdatedAt:  webhook.UpdatedAt,
+		Url:        webhook.Url,
+		FeedID:     convertNullUUIDToUUIDPtr(webhook.FeedID),
+		CategoryID: convertNullUUIDToUUIDPtr(webhook.CategoryID),
+	}
+}
+
+// databaseWebhookDeliveryToWebhookDelivery leaves the payload out, and the
+// time of the next attempt unless there is one.
+func databaseWebhookDeliveryToWebhookDelivery(delivery database.WebhookDelivery) WebhookDelivery {
+	result := WebhookDelivery{
+		ID:            delivery.ID,
+		CreatedAt:     delivery.CreatedAt,
+		UpdatedAt:     delivery.UpdatedAt,
+		PostID:        delivery.PostID,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		LastAttemptAt: convertNullTimeToTimePtr(delivery.LastAttemptAt),
+		Error:         convertNullStringToStringPtr(delivery.Error),
+	}
+	if delivery.Status == deliveryPending {
+		result.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.ResponseStatus.Valid {
+		result.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+	return result
+}
+
+func convertNullUUIDToUUIDPtr(nu uuid.NullUUID) *uuid.UUID {
+	if nu.Valid {
+		return &nu.UUID
 	}
+	return nil
 }
 func databaseFeedToFeed(feed database.Feed) Feed {
 	return Feed{
@@ -91,9 +280,19 @@ func databaseFeedToFeed(feed database.Feed) Feed {
 		Url:           feed.Url,
 		UserID:        feed.UserID,
 		LastFetchedAt: convertNullTimeToTimePtr(feed.LastFetchedAt),
+		Status:        feed.Status,
+		NotFoundSince: convertNullTimeToTimePtr(feed.NotFoundSince),
 	}
 }
 
+func databaseFeedsToFeeds(feeds []database.Feed) []Feed {
+	result := make([]Feed, len(feeds))
+	for i, feed := range feeds {
+		result[i] = databaseFeedToFeed(feed)
+	}
+	return result
+}
+
 func databaseUserToUser(user database.User) User {
 	return User{
 		ID:        user.ID,
@@ -101,7 +300,35 @@ func databaseUserToUser(user database.User) User {
 		UpdatedAt: user.UpdatedAt,
 		Name:      user.Name,
 		ApiKey:    user.ApiKey,
+		FeedToken: user.FeedToken,
+	}
+}
+
+func convertNullStringToStringPtr(ns sql.NullString) *string {
+	if ns.Valid {
+		return &ns.String
+	}
+	return nil
+}
+
+// decodeStringList decodes a JSON array column such as posts.authors. A
+// malformed value is treated as empty.
+func decodeStringList(list json.RawMessage) []string {
+	var result []string
+	json.Unmarshal(list, &result)
+	if result == nil {
+		return []string{}
+	}
+	return result
+}
+
+func decodeEnclosures(enclosures json.RawMessage) []Enclosure {
+	var result []Enclosure
+	json.Unmarshal(enclosures, &result)
+	if result == nil {
+		return []Enclosure{}
 	}
+	return result
 }
 
 func databasePostToPost(post database.Post) Post {
@@ -115,6 +342,13 @@ func databasePostToPost(post database.Post) Post {
 		Description: post.Description,
 		PublishedAt: post.PublishedAt,
 		FeedID:      post.FeedID,
+		ContentHTML: convertNullStringToStringPtr(post.ContentHtml),
+		ContentText: convertNullStringToStringPtr(post.ContentText),
+		Authors:     decodeStringList(post.Authors),
+		Categories:  decodeStringList(post.Categories),
+		GUID:        convertNullStringToStringPtr(post.Guid),
+		Enclosures:  decodeEnclosures(post.Enclosures),
+		ClusterID:   post.ClusterID,
 	}
 }
 
@@ -159,51 +393,319 @@ func (cfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
 	}
 }
 
-func main() {
-	err := godotenv.Load("./.env")
+const usage = `usage: Blog-aggr [mode]
+
+Modes:
+  serve    run the HTTP API only (requires DB and PORT)
+  scrape   run the feed scraper only (requires DB)
+  all      run both the HTTP API and the scraper (default)
+  migrate [up|down|status]
+           apply pending migrations (default), roll back the latest one or
+           list which migrations are applied (requires DB)
+
+DB is either a Postgres URL (postgres://...) or sqlite:<path> for a local
+SQLite database. Pending migrations are applied when serve, scrape or all
+start, unless DB_AUTO_MIGRATE is set to false.
+
+Database pool settings (optional):
+  DB_MAX_OPEN_CONNS      maximum open connections, 0 for no limit (default 25)
+  DB_MAX_IDLE_CONNS      maximum idle connections (default 5)
+  DB_CONN_MAX_LIFETIME   how long a connection is reused (default 30m)
+  DB_CONN_MAX_IDLE_TIME  how long a connection may sit idle (default 5m)
+  DB_CONNECT_TIMEOUT     how long to wait for the database at startup
+                         (default 10s)
+
+GET /v1/livez reports whether the process is up, GET /v1/healthz whether it
+can reach the database.
+
+GET /v1/users/{userID}/feed.atom, feed.rss and feed.json (JSON Feed) serve
+the user's posts to feed readers, authenticated by the feed_token of the user
+in ?token=. feed_id, category_id (one of the user's categories) or category
+(a tag of the posts) narrow them down.
+
+Scraper settings (optional):
+  FEED_USER_AGENT       User-Agent sent to feed hosts
+  FEED_TIMEOUT          timeout for a single fetch (default 10s)
+  FEED_MAX_REDIRECTS    redirects followed per fetch (default 5)
+  FEED_MAX_BODY_BYTES   maximum feed size in bytes (default 10485760)
+  FEED_BROKEN_AFTER     how long a feed may return 404 before being flagged
+                        as broken (default 504h)
+  FEED_DUPLICATE_WINDOW how far back new posts are compared with those of
+                        other feeds to group duplicates (default 72h)
+  HTTP_PROXY, HTTPS_PROXY, NO_PROXY
+
+Webhooks, delivered by the scraper (optional):
+  WEBHOOK_TIMEOUT       timeout for a single delivery (default 10s)
+  WEBHOOK_MAX_ATTEMPTS  attempts before a delivery is marked as failed
+                        (default 8)
+  WEBHOOK_RETRY_DELAY   wait before the first retry, doubled after each
+                        failed attempt up to 6h (default 30s)
+  WEBHOOK_ALLOW_PRIVATE_NETWORKS
+                        deliver to loopback, private and link-local
+                        addresses too (default false)
+
+Post retention, enforced by the scraper (optional, starred posts are kept):
+  RETENTION_MAX_AGE             delete posts published longer ago, e.g. 2160h
+  RETENTION_MAX_POSTS_PER_FEED  keep at most this many posts per feed
+  RETENTION_INTERVAL            how often to prune (default 1h)
+  RETENTION_BATCH_SIZE          posts deleted per statement (default 1000)
+`
+
+// openDB connects to the database named by the DB environment variable. The
+// URL scheme selects the backend: postgres:// (or postgresql://) for Postgres
+// and sqlite:<path> for a local SQLite file.
+func openDB() (*sql.DB, migrate.Dialect) {
+	dbURL := requireEnv("DB")
+
+	if path, ok := strings.CutPrefix(dbURL, "sqlite:"); ok {
+		path = strings.TrimPrefix(path, "//")
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		// Transactions take the write lock up front (_txlock=immediate) so
+		// that concurrent ones wait on busy_timeout instead of failing.
+		// Times are written in a format SQLite's date functions understand
+		// (_time_format=sqlite) rather than as time.Time.String().
+		dsn := "file:" + path + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			log.Fatalf("Couldn't open database: %v", err)
+		}
+		connectDB(db, "SQLite database "+path)
+		return db, migrate.SQLite
+	}
+
+	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		log.Fatalf("Couldn't open database: %v", err)
 	}
-	dbURL := os.Getenv("DB")
-	if dbURL == "" {
-		log.Fatalf("DB environment variable not set")
+	connectDB(db, "Postgres")
+	return db, migrate.Postgres
+}
+
+// connectDB sizes the connection pool and makes sure the database is
+// reachable, so that a bad DB setting stops the process at startup rather
+// than failing every request.
+func connectDB(db *sql.DB, name string) {
+	pool := loadDBPoolConfig()
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pool.ConnectTimeout)
+	defer cancel()
+	err := db.PingContext(ctx)
+	if err != nil {
+		log.Fatalf("Couldn't connect to %s within %s: %v", name, pool.ConnectTimeout, err)
 	}
-	port := os.Getenv("PORT")
-	if port == "" {
-		log.Fatalf("PORT environment variable not set")
+}
+
+func newMigrator(db *sql.DB, dialect migrate.Dialect) *migrate.Migrator {
+	schema := mustSub(postgresSchema, "sql/schema")
+	if dialect == migrate.SQLite {
+		schema = mustSub(sqliteSchema, "sql/sqlite/schema")
+	}
+	migrator, err := migrate.New(db, dialect, schema)
+	if err != nil {
+		log.Fatalf("Couldn't load migrations: %v", err)
 	}
+	return migrator
+}
 
-	db, err := sql.Open("postgres", dbURL)
-	dbQueries := database.New(db)
+// openStore opens the database and makes sure its schema matches this
+// binary. Pending migrations are applied unless DB_AUTO_MIGRATE is false, and
+// a database migrated by a newer release is refused.
+func openStore() database.Store {
+	db, dialect := openDB()
+	migrator := newMigrator(db, dialect)
+
+	pending, err := migrator.Pending(context.Background())
+	if errors.Is(err, migrate.ErrDatabaseAhead) {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("Couldn't check database schema: %v", err)
+	}
+	if pending > 0 {
+		if os.Getenv("DB_AUTO_MIGRATE") == "false" {
+			log.Fatalf("Database has %d pending migrations, run `Blog-aggr migrate up`", pending)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("Couldn't migrate database: %v", err)
+		}
+		log.Printf("Applied %d migrations, schema is at version %d", applied, migrator.Latest())
+	}
+
+	if dialect == migrate.SQLite {
+		return database.NewSQLiteStore(db)
+	}
+	return database.NewPostgresStore(db)
+}
+
+func runMigrate(command string) {
+	db, dialect := openDB()
+	migrator := newMigrator(db, dialect)
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Couldn't migrate database: %v", err)
+		}
+		log.Printf("Applied %d migrations, schema is at version %d", applied, migrator.Latest())
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			log.Fatalf("Couldn't roll back database: %v", err)
+		}
+		if migration == nil {
+			log.Println("No migration to roll back")
+			return
+		}
+		log.Printf("Rolled back %s", migration.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		for _, status := range statuses {
+			appliedAt := "Pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-25s %s\n", appliedAt, status.Migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+func runServer(store database.Store, port string) {
 	cfg := &apiConfig{
-		DB: dbQueries,
+		DB: store,
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: cfg.routes(),
 	}
 
+	log.Printf("Serving on port: %s\n", port)
+	log.Fatal(srv.ListenAndServe())
+}
+
+// routes returns the handler serving the API.
+func (cfg *apiConfig) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /v1/users", cfg.handlerPostUsers)
 	mux.HandleFunc("GET /v1/users", cfg.middlewareAuth(cfg.handlerGetUsers))
+	mux.HandleFunc("POST /v1/users/feed_token", cfg.middlewareAuth(cfg.handlerPostFeedToken))
+	mux.HandleFunc("GET /v1/users/{userID}/feed.atom", cfg.handlerGetUserFeedAtom)
+	mux.HandleFunc("GET /v1/users/{userID}/feed.rss", cfg.handlerGetUserFeedRSS)
+	mux.HandleFunc("GET /v1/users/{userID}/feed.json", cfg.handlerGetUserFeedJSON)
 
 	mux.HandleFunc("POST /v1/feeds", cfg.middlewareAuth(cfg.handlerPostFeeds))
 	mux.HandleFunc("GET /v1/feeds", cfg.handlerGetFeeds)
+	mux.HandleFunc("PUT /v1/feeds/{feedID}/headers", cfg.middlewareAuth(cfg.handlerPutFeedHeaders))
+	mux.HandleFunc("PUT /v1/feeds/{feedID}/status", cfg.middlewareAuth(cfg.handlerPutFeedStatus))
 
 	mux.HandleFunc("GET /v1/posts", cfg.middlewareAuth(cfg.handlerPostPost))
+	mux.HandleFunc("POST /v1/posts/{postID}/star", cfg.middlewareAuth(cfg.handlerPostPostStar))
+	mux.HandleFunc("DELETE /v1/posts/{postID}/star", cfg.middlewareAuth(cfg.handlerDeletePostStar))
 
 	mux.HandleFunc("POST /v1/feed_follows", cfg.middlewareAuth(cfg.handlerPostFeedFollows))
 	mux.HandleFunc("DELETE /v1/feed_follows/{feedFollowID}", cfg.middlewareAuth(cfg.handlerDeleteFeedFollows))
 	mux.HandleFunc("GET /v1/feed_follows", cfg.middlewareAuth(cfg.handlerFeedFollowsGet))
+	mux.HandleFunc("PATCH /v1/feed_follows/{feedFollowID}", cfg.middlewareAuth(cfg.handlerPatchFeedFollow))
+	mux.HandleFunc("PUT /v1/feed_follows/{feedFollowID}/category", cfg.middlewareAuth(cfg.handlerPutFeedFollowCategory))
 
-	mux.HandleFunc("GET /v1/healthz", handlerReadiness)
+	mux.HandleFunc("POST /v1/categories", cfg.middlewareAuth(cfg.handlerPostCategories))
+	mux.HandleFunc("GET /v1/categories", cfg.middlewareAuth(cfg.handlerGetCategories))
+	mux.HandleFunc("PUT /v1/categories/{categoryID}", cfg.middlewareAuth(cfg.handlerPutCategory))
+	mux.HandleFunc("DELETE /v1/categories/{categoryID}", cfg.middlewareAuth(cfg.handlerDeleteCategory))
+
+	mux.HandleFunc("POST /v1/filter_rules", cfg.middlewareAuth(cfg.handlerPostFilterRules))
+	mux.HandleFunc("GET /v1/filter_rules", cfg.middlewareAuth(cfg.handlerGetFilterRules))
+	mux.HandleFunc("PUT /v1/filter_rules/{ruleID}", cfg.middlewareAuth(cfg.handlerPutFilterRule))
+	mux.HandleFunc("DELETE /v1/filter_rules/{ruleID}", cfg.middlewareAuth(cfg.handlerDeleteFilterRule))
+
+	mux.HandleFunc("POST /v1/webhooks", cfg.middlewareAuth(cfg.handlerPostWebhooks))
+	mux.HandleFunc("GET /v1/webhooks", cfg.middlewareAuth(cfg.handlerGetWebhooks))
+	mux.HandleFunc("DELETE /v1/webhooks/{webhookID}", cfg.middlewareAuth(cfg.handlerDeleteWebhook))
+	mux.HandleFunc("GET /v1/webhooks/{webhookID}/deliveries", cfg.middlewareAuth(cfg.handlerGetWebhookDeliveries))
+
+	mux.HandleFunc("GET /v1/healthz", cfg.handlerReadiness)
+	mux.HandleFunc("GET /v1/livez", handlerLiveness)
 	mux.HandleFunc("GET /v1/err", handlerError)
 
-	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+	return mux
+}
+
+func runScraper(store database.Store) {
+	retention := loadRetentionConfig()
+	go startPruning(store, retention)
+	go startDelivering(store, loadWebhookConfig())
+
+	fetcher := newFeedFetcher(loadFetcherConfig())
+	startScraping(store, fetcher, scraperConfig{
+		WorkerID:           newWorkerID(),
+		Concurrency:        10,
+		TimeBetweenRequest: time.Minute,
+		LeaseDuration:      5 * time.Minute,
+		BrokenAfter:        envDuration("FEED_BROKEN_AFTER", 21*24*time.Hour),
+		DuplicateWindow:    envDuration("FEED_DUPLICATE_WINDOW", 72*time.Hour),
+		Retention:          retention,
+	})
+}
+
+func main() {
+	mode := "all"
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+	maxArgs := 2
+	if mode == "migrate" {
+		maxArgs = 3
+	}
+	if len(os.Args) > maxArgs {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
-	const collectionConcurrency = 10
-	const collectionInterval = time.Minute
-	go startScraping(dbQueries, collectionConcurrency, collectionInterval)
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(srv.ListenAndServe())
+	// The .env file is optional so that each tier can be configured purely
+	// from its environment.
+	err := godotenv.Load("./.env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+
+	switch mode {
+	case "serve":
+		port := requireEnv("PORT")
+		runServer(openStore(), port)
+	case "scrape":
+		runScraper(openStore())
+	case "migrate":
+		command := "up"
+		if len(os.Args) > 2 {
+			command = os.Args[2]
+		}
+		runMigrate(command)
+	case "all":
+		port := requireEnv("PORT")
+		store := openStore()
+		go runScraper(store)
+		runServer(store, port)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown mode %q\n\n%s", mode, usage)
+		os.Exit(2)
+	}
 }
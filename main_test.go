@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// forEachStore runs test against an empty store of each kind: the in-memory
+// one, and SQLite, whose behaviour the in-memory store must match.
+func forEachStore(t *testing.T, test func(t *testing.T, db database.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, database.NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		t.Setenv("DB", "sqlite:"+filepath.Join(t.TempDir(), "blog-aggr.db"))
+		test(t, openStore())
+	})
+}
+
+// testAPI serves the API over a store and makes requests as one of its users.
+type testAPI struct {
+	t       *testing.T
+	db      database.Store
+	handler http.Handler
+	user    User
+}
+
+func newTestAPI(t *testing.T, db database.Store) *testAPI {
+	api := &testAPI{
+		t:       t,
+		db:      db,
+		handler: (&apiConfig{DB: db}).routes(),
+	}
+	api.user = api.newUser("alice")
+	return api
+}
+
+// newUser signs up a user through the API.
+func (api *testAPI) newUser(name string) User {
+	w := api.do(http.MethodPost, "/v1/users", "", map[string]string{"name": name})
+	if w.Code != http.StatusCreated {
+		api.t.Fatalf("POST /v1/users: status %d: %s", w.Code, w.Body)
+	}
+	return decodeBody[User](api.t, w)
+}
+
+// request makes a request as api.user.
+func (api *testAPI) request(method, path string, body any) *httptest.ResponseRecorder {
+	return api.do(method, path, api.user.ApiKey, body)
+}
+
+// do makes a request with the given API key, none if empty. body is encoded
+// as JSON unless nil.
+func (api *testAPI) do(method, path, apiKey string, body any) *httptest.ResponseRecorder {
+	api.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			api.t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &payload)
+	if apiKey != "" {
+		r.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, r)
+	return w
+}
+
+func decodeBody[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(w.Body.Bytes(), &value); err != nil {
+		t.Fatalf("Couldn't decode %q: %v", w.Body, err)
+	}
+	return value
+}
+
+// createTestFeed adds a feed of userID at url, and has the user follow it.
+func createTestFeed(t *testing.T, db database.Store, userID uuid.UUID, url string) (database.Feed, database.FeedFollow) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Name:           url,
+		Url:            url,
+		UserID:         userID,
+		RequestHeaders: json.RawMessage(`{}`),
+		CanonicalUrl:   canonicalURL(url),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow, err := db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return feed, follow
+}
+
+// newTestPost returns a post of feedID as the scraper would store it, in a
+// cluster of its own.
+func newTestPost(feedID uuid.UUID, url, title string) database.CreatePostParams {
+	id := uuid.New()
+	now := time.Now().UTC()
+	return database.CreatePostParams{
+		ID:           id,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Title:        title,
+		Url:          url,
+		PublishedAt:  sql.NullTime{Time: now, Valid: true},
+		FeedID:       feedID,
+		Authors:      json.RawMessage(`[]`),
+		Categories:   json.RawMessage(`[]`),
+		Enclosures:   json.RawMessage(`[]`),
+		CanonicalUrl: canonicalURL(url),
+		TitleKey:     titleKey(title),
+		ClusterID:    id,
+	}
+}
+
+// storeTestPosts stores posts, failing the test unless they are all new.
+func storeTestPosts(t *testing.T, db database.Store, posts ...database.CreatePostParams) {
+	t.Helper()
+	ids, err := db.CreatePosts(context.Background(), posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(posts) {
+		t.Fatalf("Stored %d posts, want %d", len(ids), len(posts))
+	}
+}
+
+// postTitles returns the titles of posts, in order.
+func postTitles(posts []Post) []string {
+	titles := []string{}
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+)
+
+// retentionConfig bounds how many posts are kept. A zero MaxAge or
+// MaxPostsPerFeed disables that limit.
+type retentionConfig struct {
+	MaxAge          time.Duration
+	MaxPostsPerFeed int
+	Interval        time.Duration
+	BatchSize       int
+}
+
+// startPruning deletes the posts that fall outside the retention policy every
+// Interval. Starred posts are always kept.
+func startPruning(db database.Store, cfg retentionConfig) {
+	if cfg.MaxAge == 0 && cfg.MaxPostsPerFeed == 0 {
+		log.Println("Post retention disabled, keeping every post")
+		return
+	}
+	log.Printf("Pruning posts every %s (max age %s, max %v posts per feed)", cfg.Interval, cfg.MaxAge, cfg.MaxPostsPerFeed)
+	ticker := time.NewTicker(cfg.Interval)
+
+	for ; ; <-ticker.C {
+		prunePosts(context.Background(), db, cfg)
+	}
+}
+
+func prunePosts(ctx context.Context, db database.Store, cfg retentionConfig) {
+	start := time.Now()
+
+	var old, excess int64
+	var err error
+	if cfg.MaxAge > 0 {
+		old, err = deleteInBatches(cfg.BatchSize, func() (int64, error) {
+			return db.DeleteOldPosts(ctx, database.DeleteOldPostsParams{
+				MaxAgeSeconds: cfg.MaxAge.Seconds(),
+				BatchSize:     int32(cfg.BatchSize),
+			})
+		})
+		if err != nil {
+			log.Printf("Couldn't prune posts older than %s: %v", cfg.MaxAge, err)
+		}
+	}
+	if cfg.MaxPostsPerFeed > 0 {
+		excess, err = deleteInBatches(cfg.BatchSize, func() (int64, error) {
+			return db.DeleteExcessPosts(ctx, database.DeleteExcessPostsParams{
+				MaxPosts:  int64(cfg.MaxPostsPerFeed),
+				BatchSize: int32(cfg.BatchSize),
+			})
+		})
+		if err != nil {
+			log.Printf("Couldn't prune posts beyond %v per feed: %v", cfg.MaxPostsPerFeed, err)
+		}
+	}
+
+	log.Printf("Pruned %v posts in %s: %v too old, %v beyond the per-feed limit", old+excess, time.Since(start).Round(time.Millisecond), old, excess)
+}
+
+// retainedPosts drops the fetched posts that prunePosts would delete on its
+// next run, so that they aren't stored again on every fetch: those older than
+// MaxAge and those beyond the MaxPostsPerFeed most recent of the fetch. As in
+// DeleteOldPosts, an undated post is as old as the time it is stored.
+func (cfg retentionConfig) retainedPosts(posts []database.CreatePostParams, now time.Time) []database.CreatePostParams {
+	postTime := func(post database.CreatePostParams) time.Time {
+		if post.PublishedAt.Valid {
+			return post.PublishedAt.Time
+		}
+		return post.CreatedAt
+	}
+
+	var kept []database.CreatePostParams
+	for _, post := range posts {
+		if cfg.MaxAge == 0 || !postTime(post).Before(now.Add(-cfg.MaxAge)) {
+			kept = append(kept, post)
+		}
+	}
+	if cfg.MaxPostsPerFeed > 0 && len(kept) > cfg.MaxPostsPerFeed {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return postTime(kept[i]).After(postTime(kept[j]))
+		})
+		kept = kept[:cfg.MaxPostsPerFeed]
+	}
+	return kept
+}
+
+// deleteInBatches calls deleteBatch until it deletes less than a full batch,
+// so that no single statement holds locks on too many rows. It returns the
+// total number of rows deleted.
+func deleteInBatches(batchSize int, deleteBatch func() (int64, error)) (int64, error) {
+	var total int64
+	for {
+		deleted, err := deleteBatch()
+		total += deleted
+		if err != nil || deleted < int64(batchSize) {
+			return total, err
+		}
+	}
+}
@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,10 +22,12 @@ import (
 
 type RSS struct {
 	XMLName xml.Name `xml:"rss"`
+	Base    string   `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Channel Channel  `xml:"channel"`
 }
 
 type Channel struct {
+	Base        string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
@@ -30,47 +36,270 @@ type Channel struct {
 }
 
 type Item struct {
+	Base        string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
 	Guid        string `xml:"guid"`
+	// Content is the full article, from the content module's
+	// <content:encoded>, when the feed provides it.
+	Content    string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creators   []string       `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Author     string         `xml:"author"`
+	Categories []string       `xml:"category"`
+	Enclosures []rssEnclosure `xml:"enclosure"`
+	// Duration is the podcast episode length from <itunes:duration>
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
 }
 
-func fetchRSS(url string) (*RSS, error) {
-	httpClient := http.Client{
-		Timeout: 10 * time.Second,
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// enclosures returns the media attached to the item. The iTunes duration, if
+// any, describes the episode and so applies to each of its files.
+func (item Item) enclosures(base *url.URL) []Enclosure {
+	var duration *int64
+	if seconds, ok := parseITunesDuration(item.Duration); ok {
+		duration = &seconds
+	}
+
+	enclosures := []Enclosure{}
+	for _, enclosure := range item.Enclosures {
+		enclosureURL := resolveURL(base, enclosure.URL)
+		if enclosureURL == "" {
+			continue
+		}
+		var length *int64
+		// Feeds often set a length of 0 when they don't know it
+		if parsed, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64); err == nil && parsed > 0 {
+			length = &parsed
+		}
+		enclosures = append(enclosures, Enclosure{
+			URL:             enclosureURL,
+			MimeType:        strings.TrimSpace(enclosure.Type),
+			Length:          length,
+			DurationSeconds: duration,
+		})
+	}
+	return enclosures
+}
+
+// parseITunesDuration parses an <itunes:duration>, which is either a number
+// of seconds or of the form [HH:]MM:SS.
+func parseITunesDuration(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	var seconds float64
+	for _, part := range parts {
+		parsed, err := strconv.ParseFloat(part, 64)
+		if err != nil || parsed < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + parsed
+	}
+	return int64(seconds), true
+}
+
+// baseURL returns the URL that relative URLs of the channel are resolved
+// against: the xml:base set on the document, or failing that the channel's
+// link, both relative to the URL the document was fetched from.
+func (rss *RSS) baseURL(documentURL string) *url.URL {
+	base, err := url.Parse(documentURL)
+	if err != nil {
+		return nil
+	}
+	if rss.Base == "" && rss.Channel.Base == "" {
+		return resolveBase(base, rss.Channel.Link)
 	}
-	resp, err := httpClient.Get(url)
+	return resolveBase(resolveBase(base, rss.Base), rss.Channel.Base)
+}
+
+// resolveBase applies an xml:base style reference to base, keeping base
+// when ref is empty, invalid or leads anywhere but to an http(s) URL.
+func resolveBase(base *url.URL, ref string) *url.URL {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return base
+	}
+	resolved, err := url.Parse(ref)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch RSS feed: %v", err)
+		return base
+	}
+	if base != nil {
+		resolved = base.ResolveReference(resolved)
+	}
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return base
+	}
+	return resolved
+}
+
+// resolveURL makes ref absolute against base. Values that don't parse as
+// URLs are returned unchanged.
+func resolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == nil || ref == "" {
+		return ref
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// authors returns who wrote the item, from <dc:creator> and <author>. RSS
+// authors are e-mail addresses, usually followed by the name in parentheses,
+// in which case only the name is kept.
+func (item Item) authors() []string {
+	author := strings.TrimSpace(item.Author)
+	if start := strings.Index(author, "("); start >= 0 && strings.HasSuffix(author, ")") {
+		author = author[start+1 : len(author)-1]
 	}
-	defer resp.Body.Close()
+	authors := append([]string{}, item.Creators...)
+	return uniqueNonEmpty(append(authors, author))
+}
+
+func (item Item) categories() []string {
+	return uniqueNonEmpty(item.Categories)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
+// uniqueNonEmpty trims values and drops the empty and repeated ones, keeping
+// the original order.
+func uniqueNonEmpty(values []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
 	}
+	return result
+}
 
-	body, err := io.ReadAll(resp.Body)
+func nullString(value string) sql.NullString {
+	return sql.NullString{
+		String: value,
+		Valid:  value != "",
+	}
+}
+
+// itemToPost builds the post stored for an item of the given feed. Relative
+// URLs in the item are resolved against its xml:base, itself relative to the
+// channel's base URL.
+func itemToPost(item Item, feedID uuid.UUID, channelBase *url.URL) (database.CreatePostParams, error) {
+	base := resolveBase(channelBase, item.Base)
+
+	publishedAt := sql.NullTime{}
+	if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+		publishedAt = sql.NullTime{
+			Time:  t.UTC(),
+			Valid: true,
+		}
+	}
+	authors, err := json.Marshal(item.authors())
+	if err != nil {
+		return database.CreatePostParams{}, err
+	}
+	categories, err := json.Marshal(item.categories())
+	if err != nil {
+		return database.CreatePostParams{}, err
+	}
+	enclosures, err := json.Marshal(item.enclosures(base))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return database.CreatePostParams{}, err
+	}
+
+	// Feed HTML is untrusted: what clients get is sanitized, and the
+	// original is kept aside. The plain-text rendering comes from the full
+	// content when there is one.
+	content := strings.TrimSpace(item.Content)
+	text := content
+	if text == "" {
+		text = item.Description
 	}
+	text = htmlToText(text)
+
+	link := resolveURL(base, item.Link)
+	return database.CreatePostParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Title:     item.Title,
+		Url:       link,
+		Description: sql.NullString{
+			String: sanitizeHTML(item.Description, base),
+			Valid:  true,
+		},
+		PublishedAt:    publishedAt,
+		FeedID:         feedID,
+		ContentHtml:    nullString(sanitizeHTML(content, base)),
+		Authors:        authors,
+		Categories:     categories,
+		Guid:           nullString(strings.TrimSpace(item.Guid)),
+		Enclosures:     enclosures,
+		DescriptionRaw: nullString(item.Description),
+		ContentHtmlRaw: nullString(content),
+		ContentText:    nullString(text),
+		CanonicalUrl:   canonicalURL(link),
+		TitleKey:       titleKey(item.Title),
+		Simhash:        contentSimhash(text),
+	}, nil
+}
 
-	var rss RSS
-	err = xml.Unmarshal(body, &rss)
+// newWorkerID returns an identifier for this scraper process, used to tag the
+// feed leases it holds so that several instances can share the same database.
+func newWorkerID() string {
+	hostname, err := os.Hostname()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal XML: %v", err)
+		hostname = "unknown"
 	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
 
-	return &rss, nil
+type scraperConfig struct {
+	WorkerID           string
+	Concurrency        int
+	TimeBetweenRequest time.Duration
+	// LeaseDuration is how long a claimed feed stays locked to this worker.
+	LeaseDuration time.Duration
+	// BrokenAfter is how long a feed must keep answering 404 before it is
+	// flagged as broken.
+	BrokenAfter time.Duration
+	// DuplicateWindow is how far back new posts are compared with those of
+	// other feeds to find the same story.
+	DuplicateWindow time.Duration
+	// Retention is the policy posts are pruned with, which fetched posts
+	// are checked against before they are stored.
+	Retention retentionConfig
 }
 
-func startScraping(db *database.Queries, concurrency int, timeBetweenRequest time.Duration) {
-	log.Printf("Collecting feeds every %s on %v goroutines...", timeBetweenRequest, concurrency)
-	ticker := time.NewTicker(timeBetweenRequest)
+// startScraping claims feeds with a lease so that concurrent instances never
+// fetch the same feed at once. A lease that is not released (e.g. because the
+// process crashed) expires after LeaseDuration and the feed is picked up again.
+func startScraping(db database.Store, fetcher *feedFetcher, cfg scraperConfig) {
+	log.Printf("Collecting feeds every %s on %v goroutines as %s...", cfg.TimeBetweenRequest, cfg.Concurrency, cfg.WorkerID)
+	ticker := time.NewTicker(cfg.TimeBetweenRequest)
 
 	for ; ; <-ticker.C {
-		feeds, err := db.GetNextFeedsToFetch(context.Background(), int32(concurrency))
+		feeds, err := db.GetNextFeedsToFetch(context.Background(), database.GetNextFeedsToFetchParams{
+			LockedBy:     cfg.WorkerID,
+			LeaseSeconds: cfg.LeaseDuration.Seconds(),
+			MaxFeeds:     int32(cfg.Concurrency),
+		})
 		if err != nil {
 			log.Println("Couldn't get next feeds to fetch", err)
 			continue
@@ -80,65 +309,235 @@ func startScraping(db *database.Queries, concurrency int, timeBetweenRequest tim
 		wg := &sync.WaitGroup{}
 		for _, feed := range feeds {
 			wg.Add(1)
-			go scrapeFeed(db, wg, feed)
+			go scrapeFeed(db, fetcher, cfg, wg, feed)
 		}
 		wg.Wait()
 	}
 }
 
-func scrapeFeed(db *database.Queries, wg *sync.WaitGroup, feed database.Feed) {
+func scrapeFeed(db database.Store, fetcher *feedFetcher, cfg scraperConfig, wg *sync.WaitGroup, feed database.Feed) {
 	defer wg.Done()
 
-	// Mark feed as fetched
-	_, err := db.MarkFeedFetched(context.Background(), feed.ID)
+	// Set when the feed moved onto an existing feed and its row was deleted
+	merged := false
+
+	// Mark feed as fetched and release the lease once we're done with it
+	defer func() {
+		if merged {
+			return
+		}
+		_, err := db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
+			ID:       feed.ID,
+			LockedBy: cfg.WorkerID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Lease on feed %s expired before it was collected", feed.Name)
+			return
+		}
+		if err != nil {
+			log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
+		}
+	}()
+
+	headers, err := parseRequestHeaders(feed.RequestHeaders)
 	if err != nil {
-		log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
+		log.Printf("Couldn't decode request headers for feed %s: %v", feed.Name, err)
 		return
 	}
 
 	// Fetch RSS feed
-	feedData, err := fetchRSS(feed.Url)
+	result, err := fetcher.fetchRSS(context.Background(), feed.Url, headers)
+	updateFeedStatus(context.Background(), db, cfg, feed, err)
 	if err != nil {
-		log.Printf("Couldn't collect feed %s: %v", feed.Name, err)
+		log.Printf("Couldn't collect feed %s (%s): %v", feed.Name, fetchErrorKind(err), err)
 		return
 	}
+	feedData := result.RSS
 
-	// Insert or update posts
-	for _, item := range feedData.Channel.Items {
-		// Parse published_at time
-
-		publishedAt := sql.NullTime{}
-		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
-			publishedAt = sql.NullTime{
-				Time:  t,
-				Valid: true,
-			}
-		} // Attempt to create the post
-		_, err = db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:        uuid.New(),
-			CreatedAt: time.Time{},
-			UpdatedAt: time.Time{},
-			Title:     item.Title,
-			Url:       item.Link,
-			Description: sql.NullString{
-				String: item.Description,
-				Valid:  true,
-			},
-			PublishedAt: publishedAt,
-			FeedID:      feed.ID,
+	// Follow the feed to its new home if it moved permanently
+	feedID := feed.ID
+	if result.PermanentURL != "" && result.PermanentURL != feed.Url {
+		var movedFeed database.Feed
+		err := db.InTx(context.Background(), func(tx database.Store) error {
+			var err error
+			movedFeed, err = relocateFeed(context.Background(), tx, feed, result.PermanentURL)
+			return err
 		})
+		if err != nil {
+			log.Printf("Couldn't move feed %s to %s: %v", feed.Name, result.PermanentURL, err)
+		} else {
+			merged = movedFeed.ID != feed.ID
+			feedID = movedFeed.ID
+		}
+	}
 
-		// If the error indicates a duplicate URL, ignore it
-		if err != nil && strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			log.Printf("Post '%s' with URL '%s' already exists, skipping", item.Title, item.Link)
+	// Insert all the posts of this fetch in one batch; those already stored
+	// are skipped
+	base := feedData.baseURL(result.URL)
+	posts := make([]database.CreatePostParams, 0, len(feedData.Channel.Items))
+	for _, item := range feedData.Channel.Items {
+		post, err := itemToPost(item, feedID, base)
+		if err != nil {
+			log.Printf("Couldn't read post '%s' of feed %s: %v", item.Title, feed.Name, err)
 			continue
 		}
+		posts = append(posts, post)
+	}
+	// Leave out what the next pruning would delete
+	posts = cfg.Retention.retainedPosts(posts, time.Now().UTC())
+	// Store the posts with their clusters, rule matches and webhook
+	// deliveries all at once, so that a failure leaves none of them behind
+	var ids []uuid.UUID
+	err = db.InTx(context.Background(), func(tx database.Store) error {
+		err := assignClusters(context.Background(), tx, posts, cfg.DuplicateWindow)
+		if err != nil {
+			return fmt.Errorf("matching other feeds: %w", err)
+		}
+		ids, err = tx.CreatePosts(context.Background(), posts)
+		if err != nil {
+			return err
+		}
+		inserted := insertedPosts(posts, ids)
+		err = applyHideRules(context.Background(), tx, feedID, inserted)
+		if err != nil {
+			return fmt.Errorf("applying filter rules: %w", err)
+		}
+		err = enqueueWebhooks(context.Background(), tx, feedID, inserted)
+		if err != nil {
+			return fmt.Errorf("queueing webhooks: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Couldn't store posts of feed %s: %v", feed.Name, err)
+		return
+	}
+
+	log.Printf("Feed %s collected, %v posts found, %v new", feed.Name, len(feedData.Channel.Items), len(ids))
+}
+
+// insertedPosts keeps the posts whose ID CreatePosts returned, those that
+// weren't already stored.
+func insertedPosts(posts []database.CreatePostParams, ids []uuid.UUID) []database.CreatePostParams {
+	inserted := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		inserted[id] = true
+	}
+	var kept []database.CreatePostParams
+	for _, post := range posts {
+		if inserted[post.ID] {
+			kept = append(kept, post)
+		}
+	}
+	return kept
+}
+
+// updateFeedStatus moves a feed through its lifecycle based on the outcome of a
+// fetch: 410 Gone stops polling for good, a 404 that lasts longer than
+// BrokenAfter flags the feed as broken, and a successful fetch makes it active
+// again. Other failures are considered transient and leave the status as is.
+func updateFeedStatus(ctx context.Context, db database.Store, cfg scraperConfig, feed database.Feed, fetchErr error) {
+	if fetchErr == nil {
+		err := db.MarkFeedHealthy(ctx, feed.ID)
+		if err != nil {
+			log.Printf("Couldn't mark feed %s healthy: %v", feed.Name, err)
+		}
+		return
+	}
+
+	var statusErr *StatusError
+	if !errors.As(fetchErr, &statusErr) {
+		return
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusGone:
+		log.Printf("Feed %s is gone, it won't be fetched anymore", feed.Name)
+		err := db.MarkFeedGone(ctx, feed.ID)
+		if err != nil {
+			log.Printf("Couldn't mark feed %s gone: %v", feed.Name, err)
+		}
+	case http.StatusNotFound:
+		updated, err := db.MarkFeedNotFound(ctx, database.MarkFeedNotFoundParams{
+			BrokenAfterSeconds: cfg.BrokenAfter.Seconds(),
+			ID:                 feed.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The feed was paused in the meantime
+			return
+		}
+		if err != nil {
+			log.Printf("Couldn't record 404 for feed %s: %v", feed.Name, err)
+			return
+		}
+		if updated.Status == feedStatusBroken && feed.Status != feedStatusBroken {
+			log.Printf("Feed %s has been returning 404 since %s, flagged as broken", feed.Name, updated.NotFoundSince.Time)
+		}
+	}
+}
+
+// relocateFeed points a feed that permanently redirects to newURL. The old URL
+// is kept as an alias so that it still resolves to the feed. If another feed
+// already uses newURL, the two are merged: follows, posts and aliases move to
+// the existing feed and the redirecting one is deleted. It should run in a
+// transaction so that a merge is never left half-done.
+func relocateFeed(ctx context.Context, db database.Store, feed database.Feed, newURL string) (database.Feed, error) {
+	existing, err := db.GetFeedByURL(ctx, database.GetFeedByURLParams{
+		Url:          newURL,
+		CanonicalUrl: canonicalURL(newURL),
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, err
+	}
 
-		// Log other errors
+	if err == nil && existing.ID != feed.ID {
+		log.Printf("Feed %s moved to %s, merging into existing feed %s", feed.Name, newURL, existing.Name)
+		err = db.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{
+			TargetFeedID: existing.ID,
+			SourceFeedID: feed.ID,
+		})
+		if err != nil {
+			return database.Feed{}, err
+		}
+		err = db.MovePostsToFeed(ctx, database.MovePostsToFeedParams{
+			TargetFeedID: existing.ID,
+			SourceFeedID: feed.ID,
+		})
+		if err != nil {
+			return database.Feed{}, err
+		}
+		err = db.MoveFeedURLAliases(ctx, database.MoveFeedURLAliasesParams{
+			TargetFeedID: existing.ID,
+			SourceFeedID: feed.ID,
+		})
 		if err != nil {
-			log.Printf("Failed to insert post '%s': %v", item.Title, err)
+			return database.Feed{}, err
 		}
+		err = db.CreateFeedURLAlias(ctx, database.CreateFeedURLAliasParams{
+			Url:    feed.Url,
+			FeedID: existing.ID,
+		})
+		if err != nil {
+			return database.Feed{}, err
+		}
+		err = db.DeleteFeed(ctx, feed.ID)
+		if err != nil {
+			return database.Feed{}, err
+		}
+		return existing, nil
 	}
 
-	log.Printf("Feed %s collected, %v posts found", feed.Name, len(feedData.Channel.Items))
+	log.Printf("Feed %s moved to %s", feed.Name, newURL)
+	err = db.CreateFeedURLAlias(ctx, database.CreateFeedURLAliasParams{
+		Url:    feed.Url,
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return db.UpdateFeedURL(ctx, database.UpdateFeedURLParams{
+		ID:           feed.ID,
+		Url:          newURL,
+		CanonicalUrl: canonicalURL(newURL),
+	})
 }
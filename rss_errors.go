@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RequestError is returned when the feed couldn't be requested at all
+// (DNS failure, refused connection, timeout, ...).
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("failed to fetch feed: %v", e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the server answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.Status)
+}
+
+// BodyTooLargeError is returned when the response body exceeds the configured
+// maximum size.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// ContentTypeError is returned when the response is not plausibly a feed.
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %q", e.ContentType)
+}
+
+// ParseError is returned when the response body isn't a valid feed document.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to unmarshal XML: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// fetchErrorKind returns a short, stable category for an error returned by
+// fetchRSS, suitable for logs and fetch history.
+func fetchErrorKind(err error) string {
+	var requestErr *RequestError
+	var statusErr *StatusError
+	var tooLargeErr *BodyTooLargeError
+	var contentTypeErr *ContentTypeError
+	var parseErr *ParseError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &requestErr):
+		return "request"
+	case errors.As(err, &statusErr):
+		return "status"
+	case errors.As(err, &tooLargeErr):
+		return "too_large"
+	case errors.As(err, &contentTypeErr):
+		return "content_type"
+	case errors.As(err, &parseErr):
+		return "parse"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<link>https://blog.example/</link>
+<item>
+<title>First post</title>
+<link>https://blog.example/first?utm_source=rss</link>
+<pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+<description>&lt;p&gt;Hello&lt;/p&gt;</description>
+</item>
+<item>
+<title>Second post</title>
+<link>/second</link>
+<pubDate>Tue, 03 Jan 2006 15:04:05 -0700</pubDate>
+</item>
+</channel>
+</rss>`
+
+var testScraperConfig = scraperConfig{
+	WorkerID:        "test",
+	Concurrency:     10,
+	LeaseDuration:   time.Minute,
+	BrokenAfter:     time.Hour,
+	DuplicateWindow: time.Hour,
+}
+
+// serveFeed serves testRSS at /feed, or answers with status if it isn't 200.
+func serveFeed(t *testing.T, status *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *status != http.StatusOK {
+			w.WriteHeader(*status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, testRSS)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// scrapeNextFeeds runs the scraper once over the feeds that are due.
+func scrapeNextFeeds(t *testing.T, db database.Store) {
+	t.Helper()
+	feeds, err := db.GetNextFeedsToFetch(context.Background(), database.GetNextFeedsToFetchParams{
+		LockedBy:     testScraperConfig.WorkerID,
+		LeaseSeconds: testScraperConfig.LeaseDuration.Seconds(),
+		MaxFeeds:     int32(testScraperConfig.Concurrency),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrapeFeeds(db, feeds)
+}
+
+func scrapeFeeds(db database.Store, feeds []database.Feed) {
+	fetcher := newTestFetcher()
+	wg := &sync.WaitGroup{}
+	for _, feed := range feeds {
+		wg.Add(1)
+		go scrapeFeed(db, fetcher, testScraperConfig, wg, feed)
+	}
+	wg.Wait()
+}
+
+func getTestFeed(t *testing.T, db database.Store, id uuid.UUID) database.Feed {
+	t.Helper()
+	feeds, err := db.GetFeeds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, feed := range feeds {
+		if feed.ID == id {
+			return feed
+		}
+	}
+	t.Fatalf("Feed %s not found", id)
+	return database.Feed{}
+}
+
+func getTestPosts(t *testing.T, db database.Store, userID uuid.UUID) []Post {
+	t.Helper()
+	posts, err := db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+		UserID: userID,
+		Limit:  100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return databasePostsToPosts(posts)
+}
+
+func TestScrapeFeed(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		status := http.StatusOK
+		srv := serveFeed(t, &status)
+		userID := newTestAPI(t, db).user.ID
+		feed, _ := createTestFeed(t, db, userID, srv.URL+"/feed")
+
+		scrapeNextFeeds(t, db)
+		posts := getTestPosts(t, db, userID)
+		var urls []string
+		for _, post := range posts {
+			urls = append(urls, post.Url)
+		}
+		slices.Sort(urls)
+		want := []string{"https://blog.example/first?utm_source=rss", "https://blog.example/second"}
+		if !slices.Equal(urls, want) {
+			t.Fatalf("stored %v, want %v", urls, want)
+		}
+		fetched := getTestFeed(t, db, feed.ID)
+		if !fetched.LastFetchedAt.Valid || fetched.LockedBy.Valid {
+			t.Errorf("feed wasn't released: last fetched %v, locked by %v", fetched.LastFetchedAt, fetched.LockedBy)
+		}
+
+		// Fetching again stores nothing new
+		scrapeFeeds(db, []database.Feed{fetched})
+		if got := len(getTestPosts(t, db, userID)); got != len(want) {
+			t.Errorf("%d posts after fetching again, want %d", got, len(want))
+		}
+	})
+}
+
+func TestScrapeFeedSameURLInOtherFeed(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		status := http.StatusOK
+		srv := serveFeed(t, &status)
+		userID := newTestAPI(t, db).user.ID
+		first, _ := createTestFeed(t, db, userID, srv.URL+"/feed")
+		second, _ := createTestFeed(t, db, userID, srv.URL+"/feed?copy")
+
+		// One after the other, so that the second finds the posts of the first
+		scrapeFeeds(db, []database.Feed{first})
+		scrapeFeeds(db, []database.Feed{second})
+		perFeed := map[uuid.UUID]int{}
+		clusters := map[uuid.UUID]bool{}
+		for _, post := range getTestPosts(t, db, userID) {
+			perFeed[post.FeedID]++
+			clusters[post.ClusterID] = true
+		}
+		if perFeed[first.ID] != 2 || perFeed[second.ID] != 2 {
+			t.Fatalf("stored %d and %d posts, want 2 for each feed", perFeed[first.ID], perFeed[second.ID])
+		}
+		// Each story is published by both feeds
+		if len(clusters) != 2 {
+			t.Errorf("posts are in %d clusters, want 2", len(clusters))
+		}
+	})
+}
+
+func TestScrapeFeedHideRules(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		status := http.StatusOK
+		srv := serveFeed(t, &status)
+		api := newTestAPI(t, db)
+		feed, _ := createTestFeed(t, db, api.user.ID, srv.URL+"/feed")
+		w := api.request(http.MethodPost, "/v1/filter_rules", map[string]string{"field": "title", "pattern": "first", "action": "hide"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /v1/filter_rules: status %d: %s", w.Code, w.Body)
+		}
+		w = api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": "https://hooks.example/hook"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /v1/webhooks: status %d: %s", w.Code, w.Body)
+		}
+		webhook := decodeBody[Webhook](t, w)
+
+		// The hidden post is matched before the webhooks are queued, and
+		// fetching again queues nothing more
+		scrapeNextFeeds(t, db)
+		scrapeFeeds(db, []database.Feed{getTestFeed(t, db, feed.ID)})
+		if got := len(api.deliveries(webhook)); got != 1 {
+			t.Fatalf("queued %d deliveries, want 1", got)
+		}
+		w = api.request(http.MethodGet, "/v1/posts", nil)
+		if got, want := postTitles(decodeBody[[]Post](t, w)), []string{"Second post"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestScrapeFeedRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention retentionConfig
+		want      []string
+	}{
+		{"too old", retentionConfig{MaxAge: time.Hour}, nil},
+		{"beyond the per-feed limit", retentionConfig{MaxPostsPerFeed: 1}, []string{"Second post"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, db database.Store) {
+				status := http.StatusOK
+				srv := serveFeed(t, &status)
+				userID := newTestAPI(t, db).user.ID
+				feed, _ := createTestFeed(t, db, userID, srv.URL+"/feed")
+
+				cfg := testScraperConfig
+				cfg.Retention = tt.retention
+				wg := &sync.WaitGroup{}
+				wg.Add(1)
+				scrapeFeed(db, newTestFetcher(), cfg, wg, feed)
+				if got := postTitles(getTestPosts(t, db, userID)); !slices.Equal(got, tt.want) {
+					t.Errorf("stored %v, want %v", got, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestScrapeFeedStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		status := http.StatusOK
+		srv := serveFeed(t, &status)
+		userID := newTestAPI(t, db).user.ID
+		feed, _ := createTestFeed(t, db, userID, srv.URL+"/feed")
+		ctx := context.Background()
+
+		// A 404 is remembered, and forgotten once the feed is back
+		status = http.StatusNotFound
+		scrapeFeeds(db, []database.Feed{feed})
+		if got := getTestFeed(t, db, feed.ID); !got.NotFoundSince.Valid {
+			t.Fatal("404 wasn't recorded")
+		}
+		status = http.StatusOK
+		scrapeFeeds(db, []database.Feed{feed})
+		if got := getTestFeed(t, db, feed.ID); got.NotFoundSince.Valid || got.Status != feedStatusActive {
+			t.Fatalf("feed is %s, not found since %v after a successful fetch", got.Status, got.NotFoundSince)
+		}
+
+		// A feed paused while it was being fetched stays paused, whatever the
+		// outcome of the fetch
+		status = http.StatusNotFound
+		scrapeFeeds(db, []database.Feed{feed})
+		paused, err := db.SetFeedStatus(ctx, database.SetFeedStatusParams{
+			ID:     feed.ID,
+			UserID: userID,
+			Status: feedStatusPaused,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, status = range []int{http.StatusOK, http.StatusNotFound, http.StatusGone} {
+			scrapeFeeds(db, []database.Feed{paused})
+			if got := getTestFeed(t, db, feed.ID); got.Status != feedStatusPaused {
+				t.Fatalf("paused feed is %s after a %d", got.Status, status)
+			}
+		}
+
+		// 410 Gone stops polling
+		active, err := db.SetFeedStatus(ctx, database.SetFeedStatusParams{
+			ID:     feed.ID,
+			UserID: userID,
+			Status: feedStatusActive,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = http.StatusGone
+		scrapeFeeds(db, []database.Feed{active})
+		if got := getTestFeed(t, db, feed.ID); got.Status != feedStatusGone {
+			t.Fatalf("feed is %s after a 410, want %s", got.Status, feedStatusGone)
+		}
+	})
+}
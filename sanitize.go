@@ -0,0 +1,310 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags maps the elements kept by sanitizeHTML to the attributes they
+// may carry. Any other element is dropped but its text is kept.
+var allowedTags = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         nil,
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// droppedTags are removed along with everything inside them.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Form:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+}
+
+// blockTags start a new line in the plain-text rendering.
+var blockTags = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Br:         true,
+	atom.Div:        true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+	atom.Pre:        true,
+	atom.Tr:         true,
+	atom.Hr:         true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+	atom.Figure:     true,
+}
+
+// trackingHosts serve the invisible images feeds embed to count readers.
+var trackingHosts = []string{
+	"feeds.feedburner.com",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"www.google-analytics.com",
+	"pixel.quantserve.com",
+	"feeds.feedblitz.com",
+}
+
+// sanitizeHTML keeps only the allowlisted elements and attributes of an
+// untrusted HTML fragment, so that it can be rendered by clients as is.
+// Scripts, styles, frames, event handlers, javascript: URLs and tracking
+// pixels are removed, and the result always has balanced tags. Relative URLs
+// are resolved against base, if set.
+func sanitizeHTML(fragment string, base *url.URL) string {
+	var out strings.Builder
+	var open []atom.Atom
+	dropDepth := 0
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			// io.EOF, or malformed input that can't be tokenized further
+			break
+		}
+		token := z.Token()
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[token.DataAtom] {
+				if tokenType == html.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			attrs, ok := allowedTags[token.DataAtom]
+			if !ok {
+				continue
+			}
+			if token.DataAtom == atom.Img && !isContentImage(token) {
+				continue
+			}
+			out.WriteString("<" + token.DataAtom.String())
+			for _, attr := range token.Attr {
+				if attr.Namespace != "" || !slices.Contains(attrs, attr.Key) {
+					continue
+				}
+				value := attr.Val
+				if attr.Key == "href" || attr.Key == "src" || attr.Key == "cite" {
+					// Resolve first so that a hostile base can't smuggle
+					// in a scheme
+					var safe bool
+					value, safe = safeURL(resolveURL(base, value))
+					if !safe {
+						continue
+					}
+				}
+				out.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+			}
+			if token.DataAtom == atom.A {
+				out.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			out.WriteString(">")
+			if !isVoid(token.DataAtom) {
+				open = append(open, token.DataAtom)
+			}
+
+		case html.EndTagToken:
+			if droppedTags[token.DataAtom] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			// Close the element along with any left open inside it; stray
+			// end tags are ignored
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.DataAtom {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					out.WriteString("</" + open[j].String() + ">")
+				}
+				open = open[:i]
+				break
+			}
+
+		case html.TextToken:
+			if dropDepth > 0 {
+				continue
+			}
+			out.WriteString(html.EscapeString(token.Data))
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i].String() + ">")
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// htmlToText renders an HTML fragment as plain text, with a line break
+// between block elements and runs of whitespace collapsed.
+func htmlToText(fragment string) string {
+	var lines []string
+	var line strings.Builder
+	dropDepth := 0
+
+	flush := func() {
+		text := strings.Join(strings.Fields(line.String()), " ")
+		if text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[token.DataAtom] && tokenType == html.StartTagToken {
+				dropDepth++
+			}
+			if blockTags[token.DataAtom] {
+				flush()
+			}
+		case html.EndTagToken:
+			if droppedTags[token.DataAtom] && dropDepth > 0 {
+				dropDepth--
+			}
+			if blockTags[token.DataAtom] {
+				flush()
+			}
+		case html.TextToken:
+			if dropDepth == 0 {
+				line.WriteString(token.Data)
+			}
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// safeURL reports whether a link or image URL may be kept: relative URLs and
+// the http, https and mailto schemes are.
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto":
+		return raw, true
+	}
+	return "", false
+}
+
+// isContentImage reports whether an image is worth keeping: it must have a
+// safe source and not be a tracking pixel.
+func isContentImage(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == "src" {
+			_, safe := safeURL(attr.Val)
+			return safe && !isTrackingPixel(token)
+		}
+	}
+	return false
+}
+
+// isTrackingPixel spots the 1x1 images and known counters feeds use to track
+// their readers.
+func isTrackingPixel(token html.Token) bool {
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "width", "height":
+			value := strings.TrimSuffix(strings.TrimSpace(attr.Val), "px")
+			if value == "0" || value == "1" {
+				return true
+			}
+		case "src":
+			parsed, err := url.Parse(strings.TrimSpace(attr.Val))
+			if err != nil {
+				return false
+			}
+			if slices.Contains(trackingHosts, strings.ToLower(parsed.Hostname())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isVoid(tag atom.Atom) bool {
+	return tag == atom.Br || tag == atom.Hr || tag == atom.Img
+}
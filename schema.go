@@ -0,0 +1,20 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed sql/schema/*.sql
+var postgresSchema embed.FS
+
+//go:embed sql/sqlite/schema/*.sql
+var sqliteSchema embed.FS
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
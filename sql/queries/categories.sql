@@ -0,0 +1,22 @@
+-- name: CreateCategory :one
+INSERT INTO categories (id, created_at, updated_at, user_id, name)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+--
+
+-- name: GetCategoriesForUser :many
+SELECT * FROM categories
+WHERE user_id = $1
+ORDER BY name;
+--
+
+-- name: RenameCategory :one
+UPDATE categories
+SET name = sqlc.arg(name),
+updated_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+--
+
+-- name: DeleteCategory :execrows
+DELETE FROM categories WHERE id = $1 AND user_id = $2;
@@ -1,6 +1,14 @@
 -- name: CreateFeed :one
-insert into feeds (id, created_at, updated_at, name, url, user_id)
-values ($1, $2, $3, $4, $5, $6)
+insert into feeds (id, created_at, updated_at, name, url, user_id, request_headers, canonical_url)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+returning *;
+--
+
+-- name: UpdateFeedRequestHeaders :one
+update feeds
+set request_headers = $3,
+updated_at = NOW()
+where id = $1 and user_id = $2
 returning *;
 --
 
@@ -9,7 +17,11 @@ select * from feeds;
 --
 
 -- name: GetFeedFollowsForUser :many
-select * from feed_follows where user_id = $1;
+select feed_follows.*, feeds.name as feed_name, feeds.status as feed_status, categories.name as category_name
+from feed_follows
+join feeds on feeds.id = feed_follows.feed_id
+left join categories on categories.id = feed_follows.category_id
+where feed_follows.user_id = $1;
 --
 
 -- name: CreateFeedFollow :one
@@ -22,14 +34,128 @@ returning *;
 delete from feed_follows where id = $1 and user_id = $2;
 --
 
+-- name: UpdateFeedFollow :one
+-- Changes the settings of a follow; NULL leaves a setting as is and an empty
+-- custom_title removes it.
+update feed_follows
+set custom_title = nullif(coalesce(sqlc.narg(custom_title)::text, custom_title), ''),
+muted = coalesce(sqlc.narg(muted)::bool, muted),
+notify = coalesce(sqlc.narg(notify)::text, notify),
+updated_at = NOW()
+where id = sqlc.arg(id) and user_id = sqlc.arg(user_id)
+returning *;
+--
+
+-- name: SetFeedFollowCategory :one
+-- Files a follow into a category of the same user, or takes it out of its
+-- category if category_id is NULL. Returns no rows if either doesn't belong
+-- to the user.
+update feed_follows
+set category_id = sqlc.narg(category_id),
+updated_at = NOW()
+where feed_follows.id = sqlc.arg(id) and feed_follows.user_id = sqlc.arg(user_id)
+and (sqlc.narg(category_id)::uuid is null or exists (
+    select 1 from categories c
+    where c.id = sqlc.narg(category_id) and c.user_id = sqlc.arg(user_id)
+))
+returning *;
+--
+
 -- name: GetNextFeedsToFetch :many
-SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT $1;
+UPDATE feeds
+SET locked_by = sqlc.arg(locked_by)::text,
+locked_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE status NOT IN ('paused', 'gone')
+    AND (locked_until IS NULL OR locked_until < NOW())
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT sqlc.arg(max_feeds)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
 
 -- name: MarkFeedFetched :one
 UPDATE feeds
 SET last_fetched_at = NOW(),
+updated_at = NOW(),
+locked_by = NULL,
+locked_until = NULL
+WHERE id = sqlc.arg(id) AND locked_by = sqlc.arg(locked_by)::text
+RETURNING *;
+
+-- name: GetFeedByURL :one
+SELECT feeds.* FROM feeds
+WHERE feeds.url = sqlc.arg(url)
+OR feeds.canonical_url = sqlc.arg(canonical_url)
+OR feeds.id = (SELECT aliases.feed_id FROM feed_url_aliases aliases WHERE aliases.url = sqlc.arg(url))
+LIMIT 1;
+
+-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $2,
+canonical_url = $3,
 updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: CreateFeedURLAlias :exec
+INSERT INTO feed_url_aliases (url, feed_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id;
+
+-- name: MoveFeedURLAliases :exec
+UPDATE feed_url_aliases
+SET feed_id = sqlc.arg(target_feed_id)
+WHERE feed_id = sqlc.arg(source_feed_id);
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(target_feed_id),
+updated_at = NOW()
+WHERE feed_follows.feed_id = sqlc.arg(source_feed_id)
+AND feed_follows.user_id NOT IN (
+    SELECT target.user_id FROM feed_follows target WHERE target.feed_id = sqlc.arg(target_feed_id)
+);
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1;
+
+-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = $3,
+not_found_since = NULL,
+updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: MarkFeedGone :exec
+-- A feed paused while it was being fetched stays paused.
+UPDATE feeds
+SET status = 'gone',
+updated_at = NOW()
+WHERE id = $1
+AND status <> 'paused';
+
+-- name: MarkFeedNotFound :one
+-- Returns no rows if the feed was paused while it was being fetched.
+UPDATE feeds
+SET not_found_since = COALESCE(not_found_since, NOW()),
+status = CASE
+    WHEN not_found_since < NOW() - make_interval(secs => sqlc.arg(broken_after_seconds)::float8) THEN 'broken'
+    ELSE status
+END,
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND status <> 'paused'
+RETURNING *;
+
+-- name: MarkFeedHealthy :exec
+-- A feed paused while it was being fetched stays paused.
+UPDATE feeds
+SET status = 'active',
+not_found_since = NULL,
+updated_at = NOW()
+WHERE id = $1
+AND (status = 'broken' OR not_found_since IS NOT NULL)
+AND status <> 'paused';
@@ -0,0 +1,67 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, action)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+--
+
+-- name: GetFilterRulesForUser :many
+SELECT * FROM filter_rules
+WHERE user_id = $1
+ORDER BY created_at, id;
+--
+
+-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET feed_id = sqlc.narg(feed_id),
+field = sqlc.arg(field),
+pattern_type = sqlc.arg(pattern_type),
+pattern = sqlc.arg(pattern),
+action = sqlc.arg(action),
+updated_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+--
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules WHERE id = $1 AND user_id = $2;
+--
+
+-- name: GetHideRulesForFeed :many
+-- Returns the hide rules that apply to the posts of a feed: those of the
+-- users who follow it, scoped to the feed or to none.
+SELECT filter_rules.* FROM filter_rules
+JOIN feed_follows ON feed_follows.user_id = filter_rules.user_id
+WHERE feed_follows.feed_id = sqlc.arg(feed_id)
+AND filter_rules.action = 'hide'
+AND (filter_rules.feed_id IS NULL OR filter_rules.feed_id = sqlc.arg(feed_id))
+ORDER BY filter_rules.created_at, filter_rules.id;
+--
+
+-- name: CreatePostRuleMatch :exec
+-- Posts that were skipped as duplicates when the batch was stored don't
+-- exist, nor do rules deleted in the meantime, so nothing is recorded for
+-- them.
+INSERT INTO post_rule_matches (rule_id, post_id, created_at)
+SELECT filter_rules.id, posts.id, sqlc.arg(created_at)::timestamp
+FROM filter_rules, posts
+WHERE filter_rules.id = sqlc.arg(rule_id) AND posts.id = sqlc.arg(post_id)
+ON CONFLICT (rule_id, post_id) DO NOTHING;
+--
+
+-- name: InsertPostRuleMatchesJSON :exec
+-- Records a whole batch of matches, given as a JSON array of objects, in one
+-- statement, skipping them as CreatePostRuleMatch does.
+INSERT INTO post_rule_matches (rule_id, post_id, created_at)
+SELECT filter_rules.id, posts.id, m.created_at
+FROM jsonb_to_recordset(sqlc.arg(matches)::jsonb) AS m(
+    rule_id UUID,
+    post_id UUID,
+    created_at TIMESTAMP
+)
+JOIN filter_rules ON filter_rules.id = m.rule_id
+JOIN posts ON posts.id = m.post_id
+ON CONFLICT (rule_id, post_id) DO NOTHING;
+--
+
+-- name: DeletePostRuleMatches :exec
+DELETE FROM post_rule_matches WHERE rule_id = $1;
@@ -1,13 +1,170 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+-- Posts whose URL or canonical URL is already known in their feed are
+-- skipped, returning no rows, so that a duplicate doesn't abort the
+-- surrounding transaction.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+ON CONFLICT DO NOTHING
 RETURNING *;
 --
 
 -- name: GetPostsForUser :many
+-- Posts of muted follows are left out, unless they are asked for with
+-- feed_id, as are those a hide rule of the user matched when they were
+-- stored. feed_id, category_id and category, if set, narrow the posts down
+-- to one feed, to the feeds the user filed into one of their categories or
+-- to the posts tagged with a category. With collapse, only the first stored
+-- post of each cluster among the remaining ones is returned.
+WITH visible AS (
+    SELECT posts.id, ROW_NUMBER() OVER (
+        PARTITION BY posts.cluster_id
+        ORDER BY posts.created_at, posts.id
+    ) AS position
+    FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (NOT sqlc.arg(has_media)::bool OR jsonb_array_length(posts.enclosures) > 0)
+    AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+    AND (NOT feed_follows.muted OR sqlc.narg(feed_id)::uuid IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_rule_matches m
+        JOIN filter_rules r ON r.id = m.rule_id
+        WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+    )
+    AND (sqlc.narg(category_id)::uuid IS NULL OR feed_follows.category_id = sqlc.narg(category_id))
+    AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+        SELECT 1 FROM jsonb_array_elements_text(posts.categories) AS c(name)
+        WHERE lower(c.name) = lower(sqlc.narg(category))
+    ))
+)
 SELECT posts.* FROM posts
-JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
-WHERE feed_follows.user_id = $1
+JOIN visible ON visible.id = posts.id
+WHERE NOT sqlc.arg(collapse)::bool OR visible.position = 1
 ORDER BY posts.published_at DESC
-LIMIT $2;
+LIMIT sqlc.arg('limit');
+--
+
+-- name: GetClusterPostsForUser :many
+-- Returns the posts of the given clusters from the feeds the user follows
+-- and hasn't muted, except those a hide rule of the user matched.
+SELECT posts.* FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND NOT feed_follows.muted
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+)
+AND posts.cluster_id = ANY(sqlc.arg(cluster_ids)::uuid[])
+ORDER BY posts.created_at, posts.id;
+--
+
+-- name: GetPostFingerprintCandidates :many
+-- Returns the posts stored since the given time that new posts may match:
+-- those sharing a canonical URL or a title key with one of them, or one of
+-- the four 16-bit bands of their simhash, which any simhash a few bits away
+-- does. Each term of the condition has an index.
+SELECT p.id, p.feed_id, p.cluster_id, p.canonical_url, p.title_key, p.simhash
+FROM posts p
+WHERE p.created_at >= sqlc.arg(since)
+AND (
+    p.canonical_url = ANY(sqlc.arg(canonical_urls)::text[])
+    OR (p.title_key <> '' AND p.title_key = ANY(sqlc.arg(title_keys)::text[]))
+    OR (p.simhash <> 0 AND (p.simhash & 65535) = ANY(sqlc.arg(simhash_bands0)::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 16) & 65535) = ANY(sqlc.arg(simhash_bands1)::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 32) & 65535) = ANY(sqlc.arg(simhash_bands2)::bigint[]))
+    OR (p.simhash <> 0 AND ((p.simhash >> 48) & 65535) = ANY(sqlc.arg(simhash_bands3)::bigint[]))
+);
+--
+
+-- name: MovePostsToFeed :exec
+-- Posts the target feed already has are left behind, to be deleted along
+-- with the source feed.
+UPDATE posts
+SET feed_id = sqlc.arg(target_feed_id),
+updated_at = NOW()
+WHERE posts.feed_id = sqlc.arg(source_feed_id)
+AND posts.url NOT IN (SELECT t.url FROM posts t WHERE t.feed_id = sqlc.arg(target_feed_id))
+AND posts.canonical_url NOT IN (SELECT t.canonical_url FROM posts t WHERE t.feed_id = sqlc.arg(target_feed_id));
+--
+
+-- name: InsertPostsJSON :many
+-- Inserts a whole batch of posts, given as a JSON array of objects, in one
+-- statement. Posts whose URL or canonical URL is already known in their feed
+-- are skipped; the IDs of the new posts are returned.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content_html, p.authors, p.categories, p.guid, p.enclosures, p.description_raw, p.content_html_raw, p.content_text, p.canonical_url, p.title_key, p.simhash, p.cluster_id
+FROM jsonb_to_recordset(sqlc.arg(posts)::jsonb) AS p(
+    id UUID,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    title TEXT,
+    url TEXT,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id UUID,
+    content_html TEXT,
+    authors JSONB,
+    categories JSONB,
+    guid TEXT,
+    enclosures JSONB,
+    description_raw TEXT,
+    content_html_raw TEXT,
+    content_text TEXT,
+    canonical_url TEXT,
+    title_key TEXT,
+    simhash BIGINT,
+    cluster_id UUID
+)
+ON CONFLICT DO NOTHING
+RETURNING id;
+--
+
+-- name: UserFollowsPost :one
+-- Reports whether the post belongs to a feed the user follows.
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE posts.id = sqlc.arg(post_id) AND feed_follows.user_id = sqlc.arg(user_id)
+);
+--
+
+-- name: StarPost :exec
+INSERT INTO post_stars (user_id, post_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
 --
+
+-- name: UnstarPost :exec
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2;
+--
+
+-- name: DeleteOldPosts :execrows
+-- Deletes up to batch_size posts published (or, lacking a date, stored) more
+-- than max_age_seconds ago. Starred posts are kept.
+DELETE FROM posts
+WHERE id IN (
+    SELECT p.id FROM posts p
+    WHERE COALESCE(p.published_at, p.created_at) < NOW() - make_interval(secs => sqlc.arg(max_age_seconds)::float8)
+    AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    LIMIT sqlc.arg(batch_size)
+);
+--
+
+-- name: DeleteExcessPosts :execrows
+-- Deletes up to batch_size posts beyond the max_posts most recent ones of
+-- each feed. Starred posts are kept and don't count towards max_posts.
+DELETE FROM posts
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, ROW_NUMBER() OVER (
+            PARTITION BY p.feed_id
+            ORDER BY COALESCE(p.published_at, p.created_at) DESC
+        ) AS position
+        FROM posts p
+        WHERE NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    ) ranked
+    WHERE ranked.position > sqlc.arg(max_posts)::bigint
+    LIMIT sqlc.arg(batch_size)
+);
@@ -1,9 +1,19 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
+INSERT INTO users (id, created_at, updated_at, name, api_key, feed_token)
+VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'), $5)
 RETURNING *;
 
 -- name: GetUserByApiKey :one
 SELECT * FROM users
 WHERE api_key = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: RotateFeedToken :one
+UPDATE users
+SET feed_token = sqlc.arg(feed_token),
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
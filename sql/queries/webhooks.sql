@@ -0,0 +1,105 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, category_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+--
+
+-- name: GetWebhooksForUser :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at, id;
+--
+
+-- name: GetWebhookByID :one
+SELECT * FROM webhooks WHERE id = $1;
+--
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+--
+
+-- name: GetWebhooksForFeed :many
+-- Returns the webhooks the new posts of a feed go to: those of the users who
+-- follow it and want to be notified of its posts, scoped to the feed, to the
+-- category the user filed it into or to neither.
+SELECT webhooks.* FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+WHERE feed_follows.feed_id = sqlc.arg(feed_id)
+AND feed_follows.notify = 'all'
+AND (webhooks.feed_id IS NULL OR webhooks.feed_id = sqlc.arg(feed_id))
+AND (webhooks.category_id IS NULL OR webhooks.category_id = feed_follows.category_id)
+ORDER BY webhooks.created_at, webhooks.id;
+--
+
+-- name: CreateWebhookDelivery :exec
+-- Nothing is queued for posts that weren't stored, being duplicates, nor for
+-- those a hide rule of the webhook's owner matched.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, payload, next_attempt_at)
+SELECT sqlc.arg(id)::uuid, sqlc.arg(created_at)::timestamp, sqlc.arg(created_at)::timestamp,
+    webhooks.id, posts.id, sqlc.arg(payload)::text, sqlc.arg(created_at)::timestamp
+FROM webhooks, posts
+WHERE webhooks.id = sqlc.arg(webhook_id) AND posts.id = sqlc.arg(post_id)
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = webhooks.user_id AND r.action = 'hide'
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+--
+
+-- name: InsertWebhookDeliveriesJSON :exec
+-- Queues a whole batch of deliveries, given as a JSON array of objects, in
+-- one statement, skipping them as CreateWebhookDelivery does.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, payload, next_attempt_at)
+SELECT d.id, d.created_at, d.created_at, webhooks.id, posts.id, d.payload, d.created_at
+FROM jsonb_to_recordset(sqlc.arg(deliveries)::jsonb) AS d(
+    id UUID,
+    created_at TIMESTAMP,
+    webhook_id UUID,
+    post_id UUID,
+    payload TEXT
+)
+JOIN webhooks ON webhooks.id = d.webhook_id
+JOIN posts ON posts.id = d.post_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = webhooks.user_id AND r.action = 'hide'
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+--
+
+-- name: ClaimWebhookDeliveries :many
+-- Pushes the next attempt of the pending deliveries that are due to
+-- lease_until, so that other workers leave them alone while they are sent.
+-- A delivery whose worker died is retried once the lease runs out.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending'
+    AND d.next_attempt_at <= sqlc.arg(now)
+    ORDER BY d.next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+--
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+attempts = attempts + 1,
+next_attempt_at = sqlc.arg(next_attempt_at),
+last_attempt_at = sqlc.arg(last_attempt_at),
+response_status = sqlc.narg(response_status),
+error = sqlc.narg(error),
+updated_at = NOW()
+WHERE id = sqlc.arg(id);
+--
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN locked_by TEXT,
+ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN locked_until,
+DROP COLUMN locked_by;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'paused', 'gone', 'broken')),
+ADD COLUMN not_found_since TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN not_found_since,
+DROP COLUMN status;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content_html TEXT,
+ADD COLUMN authors JSONB NOT NULL DEFAULT '[]',
+ADD COLUMN categories JSONB NOT NULL DEFAULT '[]',
+ADD COLUMN guid TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN content_html,
+DROP COLUMN authors,
+DROP COLUMN categories,
+DROP COLUMN guid;
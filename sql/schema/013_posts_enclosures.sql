@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN enclosures JSONB NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN enclosures;
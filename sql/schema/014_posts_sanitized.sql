@@ -0,0 +1,15 @@
+-- +goose Up
+-- description and content_html now hold sanitized HTML; the originals are
+-- kept in the _raw columns. Existing posts were stored as fetched.
+ALTER TABLE posts
+ADD COLUMN description_raw TEXT,
+ADD COLUMN content_html_raw TEXT,
+ADD COLUMN content_text TEXT;
+
+UPDATE posts SET description_raw = description, content_html_raw = content_html;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN description_raw,
+DROP COLUMN content_html_raw,
+DROP COLUMN content_text;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- The feed token authenticates the user's outbound Atom and RSS feeds, whose
+-- URL is given to feed readers that can't send the ApiKey header.
+ALTER TABLE users ADD COLUMN feed_token VARCHAR(64);
+
+-- random() isn't a secure source, unlike gen_random_uuid(). New tokens are
+-- generated by the application.
+UPDATE users SET feed_token = encode(sha256((gen_random_uuid()::text || gen_random_uuid()::text)::bytea), 'hex');
+
+ALTER TABLE users ALTER COLUMN feed_token SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN feed_token;
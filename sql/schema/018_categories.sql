@@ -0,0 +1,23 @@
+-- +goose Up
+-- Categories are folders a user files their feed follows into. Deleting a
+-- category leaves its follows uncategorized.
+CREATE TABLE categories (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+ALTER TABLE feed_follows
+ADD COLUMN category_id UUID REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE INDEX feed_follows_category_id_idx ON feed_follows (category_id);
+
+-- +goose Down
+DROP INDEX feed_follows_category_id_idx;
+
+ALTER TABLE feed_follows DROP COLUMN category_id;
+
+DROP TABLE categories;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Per-user settings of a follow: a title shown instead of the feed's name,
+-- muting (the feed is still followed but left out of the timeline) and
+-- whether the user wants to be notified of its new posts.
+ALTER TABLE feed_follows
+ADD COLUMN custom_title TEXT,
+ADD COLUMN muted BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN notify TEXT NOT NULL DEFAULT 'all'
+    CHECK (notify IN ('all', 'none'));
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN notify,
+DROP COLUMN muted,
+DROP COLUMN custom_title;
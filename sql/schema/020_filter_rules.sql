@@ -0,0 +1,32 @@
+-- +goose Up
+-- Filter rules hide, highlight or mark as read the posts whose field matches
+-- a pattern, in every feed the user follows or in a single one.
+CREATE TABLE filter_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    feed_id UUID REFERENCES feeds(id) ON DELETE CASCADE,
+    field TEXT NOT NULL CHECK (field IN ('title', 'content', 'author', 'category')),
+    pattern_type TEXT NOT NULL CHECK (pattern_type IN ('literal', 'regex')),
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('hide', 'highlight', 'mark_read'))
+);
+
+CREATE INDEX filter_rules_user_id_idx ON filter_rules (user_id);
+
+-- The posts a hide rule matched when they were stored, so that they can be
+-- left out of the timeline before it is paginated.
+CREATE TABLE post_rule_matches (
+    rule_id UUID NOT NULL REFERENCES filter_rules(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (rule_id, post_id)
+);
+
+CREATE INDEX post_rule_matches_post_id_idx ON post_rule_matches (post_id);
+
+-- +goose Down
+DROP TABLE post_rule_matches;
+
+DROP TABLE filter_rules;
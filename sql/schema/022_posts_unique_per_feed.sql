@@ -0,0 +1,19 @@
+-- +goose Up
+-- URLs are unique within a feed rather than across all of them, so that an
+-- article published by several feeds is stored for each and clustered.
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+DROP INDEX posts_canonical_url_key;
+CREATE UNIQUE INDEX posts_feed_id_url_key ON posts (feed_id, url);
+CREATE UNIQUE INDEX posts_feed_id_canonical_url_key ON posts (feed_id, canonical_url);
+
+-- +goose Down
+-- Only the first stored post of each URL is kept.
+DELETE FROM posts p
+USING posts q
+WHERE (p.url = q.url OR p.canonical_url = q.canonical_url)
+AND (q.created_at, q.id) < (p.created_at, p.id);
+
+DROP INDEX posts_feed_id_canonical_url_key;
+DROP INDEX posts_feed_id_url_key;
+CREATE UNIQUE INDEX posts_canonical_url_key ON posts (canonical_url);
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
@@ -0,0 +1,17 @@
+-- +goose Up
+-- New posts are matched against the stored ones sharing their canonical URL,
+-- their title key or one of the four 16-bit bands of their simhash.
+CREATE INDEX posts_canonical_url_idx ON posts (canonical_url);
+CREATE INDEX posts_title_key_idx ON posts (title_key) WHERE title_key <> '';
+CREATE INDEX posts_simhash_band0_idx ON posts ((simhash & 65535)) WHERE simhash <> 0;
+CREATE INDEX posts_simhash_band1_idx ON posts (((simhash >> 16) & 65535)) WHERE simhash <> 0;
+CREATE INDEX posts_simhash_band2_idx ON posts (((simhash >> 32) & 65535)) WHERE simhash <> 0;
+CREATE INDEX posts_simhash_band3_idx ON posts (((simhash >> 48) & 65535)) WHERE simhash <> 0;
+
+-- +goose Down
+DROP INDEX posts_simhash_band3_idx;
+DROP INDEX posts_simhash_band2_idx;
+DROP INDEX posts_simhash_band1_idx;
+DROP INDEX posts_simhash_band0_idx;
+DROP INDEX posts_title_key_idx;
+DROP INDEX posts_canonical_url_idx;
@@ -0,0 +1,19 @@
+-- name: CreateCategory :one
+INSERT INTO categories (id, created_at, updated_at, user_id, name)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetCategoriesForUser :many
+SELECT * FROM categories
+WHERE user_id = ?
+ORDER BY name;
+
+-- name: RenameCategory :one
+UPDATE categories
+SET name = sqlc.arg(name),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteCategory :execrows
+DELETE FROM categories WHERE id = ? AND user_id = ?;
@@ -0,0 +1,149 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, request_headers, canonical_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: UpdateFeedRequestHeaders :one
+UPDATE feeds
+SET request_headers = ?,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ?
+RETURNING *;
+
+-- name: GetFeeds :many
+SELECT * FROM feeds;
+
+-- name: GetFeedFollowsForUser :many
+SELECT feed_follows.*, feeds.name AS feed_name, feeds.status AS feed_status, categories.name AS category_name
+FROM feed_follows
+JOIN feeds ON feeds.id = feed_follows.feed_id
+LEFT JOIN categories ON categories.id = feed_follows.category_id
+WHERE feed_follows.user_id = ?;
+
+-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows WHERE id = ? AND user_id = ?;
+
+-- name: UpdateFeedFollow :one
+UPDATE feed_follows
+SET custom_title = NULLIF(COALESCE(CAST(sqlc.narg(custom_title) AS TEXT), custom_title), ''),
+muted = COALESCE(CAST(sqlc.narg(muted) AS BOOLEAN), muted),
+notify = COALESCE(CAST(sqlc.narg(notify) AS TEXT), notify),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: SetFeedFollowCategory :one
+UPDATE feed_follows
+SET category_id = sqlc.narg(category_id),
+updated_at = CURRENT_TIMESTAMP
+WHERE feed_follows.id = sqlc.arg(id) AND feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(category_id) IS NULL OR EXISTS (
+    SELECT 1 FROM categories c
+    WHERE c.id = sqlc.narg(category_id) AND c.user_id = sqlc.arg(user_id)
+))
+RETURNING *;
+
+-- name: GetNextFeedsToFetch :many
+-- SQLite serializes writers, so claiming feeds with a single UPDATE is atomic
+-- without row locks.
+UPDATE feeds
+SET locked_by = sqlc.arg(locked_by),
+locked_until = datetime('now', '+' || CAST(sqlc.arg(lease_seconds) AS INTEGER) || ' seconds')
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE status NOT IN ('paused', 'gone')
+    AND (locked_until IS NULL OR locked_until < datetime('now'))
+    ORDER BY last_fetched_at IS NOT NULL, last_fetched_at ASC
+    LIMIT sqlc.arg(max_feeds)
+)
+RETURNING *;
+
+-- name: MarkFeedFetched :one
+UPDATE feeds
+SET last_fetched_at = CURRENT_TIMESTAMP,
+updated_at = CURRENT_TIMESTAMP,
+locked_by = NULL,
+locked_until = NULL
+WHERE id = sqlc.arg(id) AND locked_by = sqlc.arg(locked_by)
+RETURNING *;
+
+-- name: GetFeedByURL :one
+SELECT feeds.* FROM feeds
+WHERE feeds.url = sqlc.arg(url)
+OR feeds.canonical_url = sqlc.arg(canonical_url)
+OR feeds.id = (SELECT aliases.feed_id FROM feed_url_aliases aliases WHERE aliases.url = sqlc.arg(url))
+LIMIT 1;
+
+-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = sqlc.arg(url),
+canonical_url = sqlc.arg(canonical_url),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+AND status <> 'paused'
+RETURNING *;
+
+-- name: CreateFeedURLAlias :exec
+INSERT INTO feed_url_aliases (url, feed_id, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP)
+ON CONFLICT (url) DO UPDATE SET feed_id = excluded.feed_id;
+
+-- name: MoveFeedURLAliases :exec
+UPDATE feed_url_aliases
+SET feed_id = sqlc.arg(target_feed_id)
+WHERE feed_id = sqlc.arg(source_feed_id);
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(target_feed_id),
+updated_at = CURRENT_TIMESTAMP
+WHERE feed_follows.feed_id = sqlc.arg(source_feed_id)
+AND feed_follows.user_id NOT IN (
+    SELECT target.user_id FROM feed_follows target WHERE target.feed_id = sqlc.arg(target_feed_id)
+);
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = ?;
+
+-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = ?,
+not_found_since = NULL,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ?
+RETURNING *;
+
+-- name: MarkFeedGone :exec
+-- A feed paused while it was being fetched stays paused.
+UPDATE feeds
+SET status = 'gone',
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+AND status <> 'paused';
+
+-- name: MarkFeedNotFound :one
+-- Returns no rows if the feed was paused while it was being fetched.
+UPDATE feeds
+SET not_found_since = COALESCE(not_found_since, CURRENT_TIMESTAMP),
+status = CASE
+    WHEN not_found_since < datetime('now', '-' || CAST(sqlc.arg(broken_after_seconds) AS INTEGER) || ' seconds') THEN 'broken'
+    ELSE status
+END,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: MarkFeedHealthy :exec
+-- A feed paused while it was being fetched stays paused.
+UPDATE feeds
+SET status = 'active',
+not_found_since = NULL,
+updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+AND (status = 'broken' OR not_found_since IS NOT NULL)
+AND status <> 'paused';
@@ -0,0 +1,43 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, user_id, feed_id, field, pattern_type, pattern, action)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetFilterRulesForUser :many
+SELECT * FROM filter_rules
+WHERE user_id = ?
+ORDER BY julianday(created_at), id;
+
+-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET feed_id = sqlc.narg(feed_id),
+field = sqlc.arg(field),
+pattern_type = sqlc.arg(pattern_type),
+pattern = sqlc.arg(pattern),
+action = sqlc.arg(action),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules WHERE id = ? AND user_id = ?;
+
+-- name: GetHideRulesForFeed :many
+SELECT filter_rules.* FROM filter_rules
+JOIN feed_follows ON feed_follows.user_id = filter_rules.user_id
+WHERE feed_follows.feed_id = sqlc.arg(feed_id)
+AND filter_rules.action = 'hide'
+AND (filter_rules.feed_id IS NULL OR filter_rules.feed_id = sqlc.arg(feed_id))
+ORDER BY julianday(filter_rules.created_at), filter_rules.id;
+
+-- name: CreatePostRuleMatch :exec
+-- The WHERE clause keeps SQLite from reading ON CONFLICT as part of the
+-- SELECT.
+INSERT INTO post_rule_matches (rule_id, post_id, created_at)
+SELECT filter_rules.id, posts.id, sqlc.arg(created_at)
+FROM filter_rules, posts
+WHERE filter_rules.id = sqlc.arg(rule_id) AND posts.id = sqlc.arg(post_id)
+ON CONFLICT (rule_id, post_id) DO NOTHING;
+
+-- name: DeletePostRuleMatches :exec
+DELETE FROM post_rule_matches WHERE rule_id = ?;
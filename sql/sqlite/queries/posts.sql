@@ -0,0 +1,117 @@
+-- name: CreatePost :one
+-- Posts whose URL or canonical URL is already known in their feed are
+-- skipped, returning no rows, so that a duplicate doesn't abort the
+-- surrounding transaction.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content_html, authors, categories, guid, enclosures, description_raw, content_html_raw, content_text, canonical_url, title_key, simhash, cluster_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: GetPostsForUser :many
+-- Times are compared with julianday(), which unlike datetime() keeps
+-- fractions of a second and unlike the stored text accounts for their UTC
+-- offset.
+WITH visible AS (
+    SELECT posts.id, ROW_NUMBER() OVER (
+        PARTITION BY posts.cluster_id
+        ORDER BY julianday(posts.created_at), posts.id
+    ) AS position
+    FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (CAST(sqlc.arg(has_media) AS BOOLEAN) = FALSE OR json_array_length(posts.enclosures) > 0)
+    AND (sqlc.narg(feed_id) IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+    AND (NOT feed_follows.muted OR sqlc.narg(feed_id) IS NOT NULL)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_rule_matches m
+        JOIN filter_rules r ON r.id = m.rule_id
+        WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+    )
+    AND (sqlc.narg(category_id) IS NULL OR feed_follows.category_id = sqlc.narg(category_id))
+    AND (sqlc.narg(category) IS NULL OR EXISTS (
+        SELECT 1 FROM json_each(posts.categories) AS c
+        WHERE lower(c.value) = lower(sqlc.narg(category))
+    ))
+)
+SELECT posts.* FROM posts
+JOIN visible ON visible.id = posts.id
+WHERE CAST(sqlc.arg(collapse) AS BOOLEAN) = FALSE OR visible.position = 1
+ORDER BY posts.published_at IS NOT NULL, julianday(posts.published_at) DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetClusterPostsForUser :many
+SELECT posts.* FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND NOT feed_follows.muted
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = feed_follows.user_id AND r.action = 'hide'
+)
+AND posts.cluster_id IN (sqlc.slice(cluster_ids))
+ORDER BY julianday(posts.created_at), posts.id;
+
+-- name: GetPostFingerprintCandidates :many
+-- Takes the fingerprint of a single post, the bands being -1 for posts
+-- without a simhash.
+SELECT p.id, p.feed_id, p.cluster_id, p.canonical_url, p.title_key, p.simhash
+FROM posts p
+WHERE datetime(p.created_at) >= datetime(sqlc.arg(since))
+AND (
+    p.canonical_url = sqlc.arg(canonical_url)
+    OR (p.title_key <> '' AND p.title_key = sqlc.arg(title_key))
+    OR (p.simhash <> 0 AND (p.simhash & 65535) = sqlc.arg(simhash_band0))
+    OR (p.simhash <> 0 AND ((p.simhash >> 16) & 65535) = sqlc.arg(simhash_band1))
+    OR (p.simhash <> 0 AND ((p.simhash >> 32) & 65535) = sqlc.arg(simhash_band2))
+    OR (p.simhash <> 0 AND ((p.simhash >> 48) & 65535) = sqlc.arg(simhash_band3))
+);
+
+-- name: MovePostsToFeed :exec
+UPDATE posts
+SET feed_id = sqlc.arg(target_feed_id),
+updated_at = CURRENT_TIMESTAMP
+WHERE posts.feed_id = sqlc.arg(source_feed_id)
+AND posts.url NOT IN (SELECT t.url FROM posts t WHERE t.feed_id = sqlc.arg(target_feed_id))
+AND posts.canonical_url NOT IN (SELECT t.canonical_url FROM posts t WHERE t.feed_id = sqlc.arg(target_feed_id));
+
+-- name: UserFollowsPost :one
+SELECT EXISTS (
+    SELECT 1 FROM posts
+    JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+    WHERE posts.id = sqlc.arg(post_id) AND feed_follows.user_id = sqlc.arg(user_id)
+);
+
+-- name: StarPost :exec
+INSERT INTO post_stars (user_id, post_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnstarPost :exec
+DELETE FROM post_stars WHERE user_id = ? AND post_id = ?;
+
+-- name: DeleteOldPosts :execrows
+-- Timestamps are compared through datetime() since they are stored as text
+-- with the offset they were parsed with.
+DELETE FROM posts
+WHERE id IN (
+    SELECT p.id FROM posts p
+    WHERE datetime(COALESCE(p.published_at, p.created_at)) < datetime('now', '-' || CAST(sqlc.arg(max_age_seconds) AS INTEGER) || ' seconds')
+    AND NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT p.id, ROW_NUMBER() OVER (
+            PARTITION BY p.feed_id
+            ORDER BY datetime(COALESCE(p.published_at, p.created_at)) DESC
+        ) AS position
+        FROM posts p
+        WHERE NOT EXISTS (SELECT 1 FROM post_stars s WHERE s.post_id = p.id)
+    ) ranked
+    WHERE ranked.position > CAST(sqlc.arg(max_posts) AS INTEGER)
+    LIMIT sqlc.arg(batch_size)
+);
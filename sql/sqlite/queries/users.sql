@@ -0,0 +1,19 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name, api_key, feed_token)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserByApiKey :one
+SELECT * FROM users
+WHERE api_key = ?;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: RotateFeedToken :one
+UPDATE users
+SET feed_token = sqlc.arg(feed_token),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING *;
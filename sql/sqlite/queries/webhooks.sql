@@ -0,0 +1,71 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, category_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT * FROM webhooks
+WHERE user_id = ?
+ORDER BY julianday(created_at), id;
+
+-- name: GetWebhookByID :one
+SELECT * FROM webhooks WHERE id = ?;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = ? AND user_id = ?;
+
+-- name: GetWebhooksForFeed :many
+SELECT webhooks.* FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+WHERE feed_follows.feed_id = sqlc.arg(feed_id)
+AND feed_follows.notify = 'all'
+AND (webhooks.feed_id IS NULL OR webhooks.feed_id = sqlc.arg(feed_id))
+AND (webhooks.category_id IS NULL OR webhooks.category_id = feed_follows.category_id)
+ORDER BY julianday(webhooks.created_at), webhooks.id;
+
+-- name: CreateWebhookDelivery :exec
+-- The WHERE clause keeps SQLite from reading ON CONFLICT as part of the
+-- SELECT.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, payload, next_attempt_at)
+SELECT sqlc.arg(id), sqlc.arg(created_at), sqlc.arg(created_at),
+    webhooks.id, posts.id, sqlc.arg(payload), sqlc.arg(created_at)
+FROM webhooks, posts
+WHERE webhooks.id = sqlc.arg(webhook_id) AND posts.id = sqlc.arg(post_id)
+AND NOT EXISTS (
+    SELECT 1 FROM post_rule_matches m
+    JOIN filter_rules r ON r.id = m.rule_id
+    WHERE m.post_id = posts.id AND r.user_id = webhooks.user_id AND r.action = 'hide'
+)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+-- SQLite serializes writers, so claiming deliveries with a single UPDATE is
+-- atomic without row locks. Times are compared through julianday() since
+-- they are stored as text.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending'
+    AND julianday(d.next_attempt_at) <= julianday(sqlc.arg(now))
+    ORDER BY julianday(d.next_attempt_at)
+    LIMIT sqlc.arg(max_deliveries)
+)
+RETURNING *;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+attempts = attempts + 1,
+next_attempt_at = sqlc.arg(next_attempt_at),
+last_attempt_at = sqlc.arg(last_attempt_at),
+response_status = sqlc.narg(response_status),
+error = sqlc.narg(error),
+updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY julianday(created_at) DESC, id
+LIMIT ?;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQLite can't add a NOT NULL column without a default, so existing users get
+-- an empty key that is replaced right away
+ALTER TABLE users ADD COLUMN api_key TEXT NOT NULL DEFAULT '';
+
+UPDATE users SET api_key = lower(hex(randomblob(32))) WHERE api_key = '';
+
+CREATE UNIQUE INDEX users_api_key_unique ON users (api_key);
+
+-- +goose Down
+DROP INDEX users_api_key_unique;
+
+ALTER TABLE users DROP COLUMN api_key;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN locked_by TEXT;
+
+ALTER TABLE feeds ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN locked_until;
+
+ALTER TABLE feeds DROP COLUMN locked_by;
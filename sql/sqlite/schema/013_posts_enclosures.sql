@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN enclosures TEXT NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN enclosures;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Existing rows keep their URL as canonical form; new ones are normalized by
+-- the application.
+ALTER TABLE feeds ADD COLUMN canonical_url TEXT NOT NULL DEFAULT '';
+UPDATE feeds SET canonical_url = url;
+CREATE UNIQUE INDEX feeds_canonical_url_key ON feeds (canonical_url);
+
+ALTER TABLE posts ADD COLUMN canonical_url TEXT NOT NULL DEFAULT '';
+UPDATE posts SET canonical_url = url;
+CREATE UNIQUE INDEX posts_canonical_url_key ON posts (canonical_url);
+
+-- +goose Down
+DROP INDEX posts_canonical_url_key;
+ALTER TABLE posts DROP COLUMN canonical_url;
+
+DROP INDEX feeds_canonical_url_key;
+ALTER TABLE feeds DROP COLUMN canonical_url;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Posts telling the same story share a cluster_id, the id of the first post
+-- of the cluster. title_key and simhash are the fingerprint used to find it;
+-- existing posts are left alone in their own cluster.
+ALTER TABLE posts ADD COLUMN title_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN simhash INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN cluster_id UUID NOT NULL DEFAULT '';
+
+UPDATE posts SET cluster_id = id;
+
+CREATE INDEX posts_cluster_id_idx ON posts (cluster_id);
+CREATE INDEX posts_created_at_idx ON posts (created_at);
+
+-- +goose Down
+DROP INDEX posts_created_at_idx;
+DROP INDEX posts_cluster_id_idx;
+
+ALTER TABLE posts DROP COLUMN cluster_id;
+ALTER TABLE posts DROP COLUMN simhash;
+ALTER TABLE posts DROP COLUMN title_key;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- The feed token authenticates the user's outbound Atom and RSS feeds, whose
+-- URL is given to feed readers that can't send the ApiKey header.
+ALTER TABLE users ADD COLUMN feed_token TEXT NOT NULL DEFAULT '';
+
+UPDATE users SET feed_token = lower(hex(randomblob(32))) WHERE feed_token = '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN feed_token;
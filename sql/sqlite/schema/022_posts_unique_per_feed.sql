@@ -0,0 +1,63 @@
+-- +goose Up
+-- URLs are unique within a feed rather than across all of them, so that an
+-- article published by several feeds is stored for each and clustered.
+-- SQLite can't drop the UNIQUE constraint of a column, so the table is
+-- rebuilt. Dropping it cascades to the rows referencing posts, which are
+-- copied beforehand and restored.
+CREATE TABLE posts_new (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    content_html TEXT,
+    authors TEXT NOT NULL DEFAULT '[]',
+    categories TEXT NOT NULL DEFAULT '[]',
+    guid TEXT,
+    enclosures TEXT NOT NULL DEFAULT '[]',
+    description_raw TEXT,
+    content_html_raw TEXT,
+    content_text TEXT,
+    canonical_url TEXT NOT NULL DEFAULT '',
+    title_key TEXT NOT NULL DEFAULT '',
+    simhash INTEGER NOT NULL DEFAULT 0,
+    cluster_id UUID NOT NULL DEFAULT ''
+);
+INSERT INTO posts_new SELECT * FROM posts;
+
+CREATE TEMP TABLE post_stars_copy AS SELECT * FROM post_stars;
+CREATE TEMP TABLE post_rule_matches_copy AS SELECT * FROM post_rule_matches;
+CREATE TEMP TABLE webhook_deliveries_copy AS SELECT * FROM webhook_deliveries;
+
+DROP TABLE posts;
+ALTER TABLE posts_new RENAME TO posts;
+
+INSERT INTO post_stars SELECT * FROM post_stars_copy;
+INSERT INTO post_rule_matches SELECT * FROM post_rule_matches_copy;
+INSERT INTO webhook_deliveries SELECT * FROM webhook_deliveries_copy;
+DROP TABLE post_stars_copy;
+DROP TABLE post_rule_matches_copy;
+DROP TABLE webhook_deliveries_copy;
+
+CREATE INDEX posts_cluster_id_idx ON posts (cluster_id);
+CREATE INDEX posts_created_at_idx ON posts (created_at);
+CREATE UNIQUE INDEX posts_feed_id_url_key ON posts (feed_id, url);
+CREATE UNIQUE INDEX posts_feed_id_canonical_url_key ON posts (feed_id, canonical_url);
+
+-- +goose Down
+-- Only the first stored post of each URL is kept.
+DELETE FROM posts
+WHERE EXISTS (
+    SELECT 1 FROM posts q
+    WHERE (q.url = posts.url OR q.canonical_url = posts.canonical_url)
+    AND (julianday(q.created_at) < julianday(posts.created_at)
+        OR (julianday(q.created_at) = julianday(posts.created_at) AND q.id < posts.id))
+);
+
+DROP INDEX posts_feed_id_canonical_url_key;
+DROP INDEX posts_feed_id_url_key;
+CREATE UNIQUE INDEX posts_canonical_url_key ON posts (canonical_url);
+CREATE UNIQUE INDEX posts_url_key ON posts (url);
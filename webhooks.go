@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// Statuses of a webhook delivery, stored in webhook_deliveries.status.
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const (
+	// webhookEventPostCreated is sent for each new post, in the
+	// X-Blog-Aggr-Event header and the payload.
+	webhookEventPostCreated = "post.created"
+	// maxWebhookRetryDelay caps the backoff between two attempts.
+	maxWebhookRetryDelay = 6 * time.Hour
+)
+
+// webhookConfig controls how webhook deliveries are sent and retried.
+type webhookConfig struct {
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked as failed.
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry, doubled after each
+	// failed attempt.
+	RetryDelay time.Duration
+	Interval   time.Duration
+	BatchSize  int
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses, which are refused by default.
+	AllowPrivateNetworks bool
+}
+
+// nonPublicPrefixes are the special-purpose ranges not covered by the
+// netip.Addr predicates that webhooks may not reach either.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// webhookPayload is the JSON body posted to webhooks.
+type webhookPayload struct {
+	Event string `json:"event"`
+	Post  Post   `json:"post"`
+}
+
+// enqueueWebhooks queues the posts a fetch inserted for the webhooks of the
+// feed's followers, in a single batch.
+func enqueueWebhooks(ctx context.Context, db database.Store, feedID uuid.UUID, posts []database.CreatePostParams) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	webhooks, err := db.GetWebhooksForFeed(ctx, feedID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	now := time.Now().UTC()
+	deliveries := make([]database.CreateWebhookDeliveryParams, 0, len(posts)*len(webhooks))
+	for _, post := range posts {
+		payload, err := json.Marshal(webhookPayload{
+			Event: webhookEventPostCreated,
+			Post:  databasePostToPost(database.Post(post)),
+		})
+		if err != nil {
+			return err
+		}
+		for _, webhook := range webhooks {
+			deliveries = append(deliveries, database.CreateWebhookDeliveryParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				Payload:   string(payload),
+				WebhookID: webhook.ID,
+				PostID:    post.ID,
+			})
+		}
+	}
+	return db.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// startDelivering sends the pending webhook deliveries every Interval.
+func startDelivering(db database.Store, cfg webhookConfig) {
+	log.Printf("Delivering webhooks every %s (%v attempts at most)", cfg.Interval, cfg.MaxAttempts)
+	client := newWebhookClient(cfg)
+	ticker := time.NewTicker(cfg.Interval)
+
+	for ; ; <-ticker.C {
+		deliverWebhooks(context.Background(), db, client, cfg)
+	}
+}
+
+// newWebhookClient returns the client deliveries are sent with. Redirects
+// are not followed, and unless AllowPrivateNetworks is set the address each
+// connection is made to is checked once resolved, so that a webhook can't
+// reach the server's own network whatever its host resolves to.
+func newWebhookClient(cfg webhookConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := &http.Transport{
+		// A proxy would make the dialed address its own rather than the
+		// webhook's
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		// A redirect would turn the POST into a GET, and could point at an
+		// address that is refused; report it instead
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refusePrivateAddress is a net.Dialer Control function that fails
+// connections to addresses that aren't public unicast ones.
+func refusePrivateAddress(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not public", addrPort.Addr())
+	}
+	return nil
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// deliverWebhooks sends a batch of the deliveries that are due. They are
+// leased long enough for a single attempt, so that another scraper doesn't
+// send them at the same time.
+func deliverWebhooks(ctx context.Context, db database.Store, client *http.Client, cfg webhookConfig) {
+	now := time.Now().UTC()
+	deliveries, err := db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil:    now.Add(cfg.Timeout + time.Minute),
+		Now:           now,
+		MaxDeliveries: int32(cfg.BatchSize),
+	})
+	if err != nil {
+		log.Printf("Couldn't get webhook deliveries: %v", err)
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery database.WebhookDelivery) {
+			defer wg.Done()
+			deliverWebhook(ctx, db, client, cfg, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// deliverWebhook makes one attempt at a delivery and records its outcome:
+// delivered on a 2xx response, retried later otherwise, until it runs out of
+// attempts.
+func deliverWebhook(ctx context.Context, db database.Store, client *http.Client, cfg webhookConfig, delivery database.WebhookDelivery) {
+	webhook, err := db.GetWebhookByID(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Printf("Couldn't get webhook %s: %v", delivery.WebhookID, err)
+		return
+	}
+
+	status, sendErr := sendWebhook(ctx, client, webhook, delivery)
+	now := time.Now().UTC()
+	attempt := database.RecordWebhookAttemptParams{
+		ID:            delivery.ID,
+		Status:        deliveryDelivered,
+		NextAttemptAt: now,
+		LastAttemptAt: sql.NullTime{Time: now, Valid: true},
+	}
+	if status != 0 {
+		attempt.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		attempt.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+		attempts := int(delivery.Attempts) + 1
+		if attempts >= cfg.MaxAttempts {
+			attempt.Status = deliveryFailed
+			log.Printf("Giving up on delivery %s to webhook %s after %v attempts: %v", delivery.ID, webhook.ID, attempts, sendErr)
+		} else {
+			attempt.Status = deliveryPending
+			attempt.NextAttemptAt = now.Add(webhookRetryDelay(cfg.RetryDelay, attempts))
+		}
+	}
+
+	err = db.RecordWebhookAttempt(ctx, attempt)
+	if err != nil {
+		log.Printf("Couldn't record delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// webhookRetryDelay returns how long to wait after a delivery failed for the
+// given number of times: base, then twice as long after each failure, up to
+// maxWebhookRetryDelay.
+func webhookRetryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxWebhookRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookRetryDelay)
+}
+
+// sendWebhook posts a delivery's payload to its webhook, signed with the
+// webhook's secret. It returns the response status, 0 if there was no
+// response, and an error unless the status is 2xx.
+func sendWebhook(ctx context.Context, client *http.Client, webhook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("X-Blog-Aggr-Event", webhookEventPostCreated)
+	req.Header.Set("X-Blog-Aggr-Delivery", delivery.ID.String())
+	req.Header.Set("X-Blog-Aggr-Signature", "sha256="+signPayload(webhook.Secret, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read some of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// signPayload returns the hex-encoded HMAC-SHA256 of a payload, which
+// receivers recompute with the webhook's secret to check that the payload
+// came from us.
+func signPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
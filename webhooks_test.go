@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/L-PDufour/Blog-aggr/internal/database"
+	"github.com/google/uuid"
+)
+
+// webhookReceiver records the requests made to a test webhook and answers
+// them with status.
+type webhookReceiver struct {
+	*httptest.Server
+	status   atomic.Int32
+	requests atomic.Int32
+}
+
+func newWebhookReceiver(t *testing.T, secret *string) *webhookReceiver {
+	receiver := &webhookReceiver{}
+	receiver.status.Store(http.StatusOK)
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receiver.requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Blog-Aggr-Signature"), "sha256="+signPayload(*secret, string(body)); got != want {
+			t.Errorf("signature %q, want %q", got, want)
+		}
+		if got := r.Header.Get("X-Blog-Aggr-Event"); got != webhookEventPostCreated {
+			t.Errorf("event %q, want %q", got, webhookEventPostCreated)
+		}
+		w.WriteHeader(int(receiver.status.Load()))
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// deliveries returns the deliveries of a webhook through the API.
+func (api *testAPI) deliveries(webhook Webhook) []WebhookDelivery {
+	w := api.request(http.MethodGet, "/v1/webhooks/"+webhook.ID.String()+"/deliveries", nil)
+	if w.Code != http.StatusOK {
+		api.t.Fatalf("GET deliveries: status %d: %s", w.Code, w.Body)
+	}
+	return decodeBody[[]WebhookDelivery](api.t, w)
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		ctx := context.Background()
+		api := newTestAPI(t, db)
+		var secret string
+		receiver := newWebhookReceiver(t, &secret)
+		w := api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": receiver.URL + "/hook"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /v1/webhooks: status %d: %s", w.Code, w.Body)
+		}
+		webhook := decodeBody[Webhook](t, w)
+		secret = webhook.Secret
+
+		cfg := webhookConfig{
+			Timeout:              5 * time.Second,
+			MaxAttempts:          2,
+			RetryDelay:           time.Millisecond,
+			BatchSize:            10,
+			AllowPrivateNetworks: true,
+		}
+		client := newWebhookClient(cfg)
+		feed, _ := createTestFeed(t, db, api.user.ID, "https://x.example/feed")
+		deliver := func(post database.CreatePostParams) WebhookDelivery {
+			t.Helper()
+			storeTestPosts(t, db, post)
+			if err := enqueueWebhooks(ctx, db, feed.ID, []database.CreatePostParams{post}); err != nil {
+				t.Fatal(err)
+			}
+			deliverWebhooks(ctx, db, client, cfg)
+			for _, delivery := range api.deliveries(webhook) {
+				if delivery.PostID == post.ID {
+					return delivery
+				}
+			}
+			t.Fatalf("No delivery of post %s", post.ID)
+			return WebhookDelivery{}
+		}
+
+		delivery := deliver(newTestPost(feed.ID, "https://x.example/1", "Delivered"))
+		if delivery.Status != deliveryDelivered || delivery.Attempts != 1 || delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusOK {
+			t.Fatalf("delivery is %s after %d attempts, response %v", delivery.Status, delivery.Attempts, delivery.ResponseStatus)
+		}
+
+		// A failed delivery is retried until it runs out of attempts
+		receiver.status.Store(http.StatusInternalServerError)
+		delivery = deliver(newTestPost(feed.ID, "https://x.example/2", "Failed"))
+		if delivery.Status != deliveryPending || delivery.Attempts != 1 || delivery.Error == nil {
+			t.Fatalf("delivery is %s after %d attempts, error %v", delivery.Status, delivery.Attempts, delivery.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+		deliverWebhooks(ctx, db, client, cfg)
+		delivery = api.deliveries(webhook)[0]
+		if delivery.Status != deliveryFailed || delivery.Attempts != 2 {
+			t.Fatalf("delivery is %s after %d attempts, want %s", delivery.Status, delivery.Attempts, deliveryFailed)
+		}
+		if got := receiver.requests.Load(); got != 3 {
+			t.Errorf("webhook got %d requests, want 3", got)
+		}
+	})
+}
+
+func TestEnqueueWebhooksInsertedPostsOnly(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db database.Store) {
+		ctx := context.Background()
+		api := newTestAPI(t, db)
+		w := api.request(http.MethodPost, "/v1/webhooks", map[string]string{"url": "https://hooks.example/hook"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /v1/webhooks: status %d: %s", w.Code, w.Body)
+		}
+		webhook := decodeBody[Webhook](t, w)
+		feed, _ := createTestFeed(t, db, api.user.ID, "https://x.example/feed")
+		storeTestPosts(t, db, newTestPost(feed.ID, "https://x.example/1", "Known"))
+
+		// The first post is fetched again, under a new ID
+		posts := []database.CreatePostParams{
+			newTestPost(feed.ID, "https://x.example/1", "Known"),
+			newTestPost(feed.ID, "https://x.example/2", "New"),
+		}
+		ids, err := db.CreatePosts(ctx, posts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, []uuid.UUID{posts[1].ID}) {
+			t.Fatalf("CreatePosts returned %v, want only %s", ids, posts[1].ID)
+		}
+		if err := enqueueWebhooks(ctx, db, feed.ID, insertedPosts(posts, ids)); err != nil {
+			t.Fatal(err)
+		}
+		deliveries := api.deliveries(webhook)
+		if len(deliveries) != 1 || deliveries[0].PostID != posts[1].ID {
+			t.Fatalf("queued %v, want a single delivery of %s", deliveries, posts[1].ID)
+		}
+	})
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	var secret string
+	receiver := newWebhookReceiver(t, &secret)
+
+	_, err := newWebhookClient(webhookConfig{Timeout: 5 * time.Second}).Post(receiver.URL, "application/json", nil)
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Errorf("got error %v for a loopback address", err)
+	}
+	if got := receiver.requests.Load(); got != 0 {
+		t.Errorf("webhook got %d requests, want none", got)
+	}
+}
+
+func TestWebhookClientDoesntFollowRedirects(t *testing.T) {
+	redirect := httptest.NewServer(http.RedirectHandler("http://169.254.169.254/", http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	resp, err := newWebhookClient(webhookConfig{Timeout: 5 * time.Second, AllowPrivateNetworks: true}).Post(redirect.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("status %d, want the redirect itself", resp.StatusCode)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, maxWebhookRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(30*time.Second, tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(30s, %d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}